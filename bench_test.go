@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
+)
+
+// BenchmarkListTasks measures the list command, from loading the task file
+// to printing every task, on lists of 1,000, 10,000 and 100,000 tasks.
+func BenchmarkListTasks(b *testing.B) {
+	defer SetStore(tracker.NewJSONFileStore("tasks.json"))
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			path := filepath.Join(b.TempDir(), "tasks.json")
+			now := time.Now()
+			list := &tracker.TaskList{NextID: n + 1, Tasks: make([]tracker.Task, n)}
+			for i := range list.Tasks {
+				list.Tasks[i] = tracker.Task{ID: i + 1, Description: "Task number " + strconv.Itoa(i), Status: tracker.StatusTodo, CreatedAt: now, UpdatedAt: now}
+			}
+			if err := tracker.NewJSONFileStore(path).Save(list); err != nil {
+				b.Fatal(err)
+			}
+
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer devNull.Close()
+			stdout := os.Stdout
+			os.Stdout = devNull
+			defer func() { os.Stdout = stdout }()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Every run of task-cli starts with a fresh store
+				SetStore(tracker.NewJSONFileStore(path))
+				if err := listTasks(tracker.Query{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
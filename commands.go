@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
+)
+
+// listBackups prints the backups of the task file at path, newest first,
+// with the number of tasks in each.
+func listBackups(path string) error {
+	backups, err := tracker.ListBackups(path)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Println("No backups found.")
+		return nil
+	}
+
+	for _, b := range backups {
+		list, err := tasks().ReadTaskFile(b.Path)
+		if err != nil {
+			fmt.Printf("%s  %s  unreadable: %v\n", b.Name, b.At.Local().Format("2006-01-02 15:04:05"), err)
+			continue
+		}
+		fmt.Printf("%s  %s  %d tasks, %d in trash\n", b.Name, b.At.Local().Format("2006-01-02 15:04:05"), len(list.Live()), len(list.Trash()))
+	}
+	return nil
+}
+
+// restoreBackup restores the named backup of the task file at path after
+// printing what it would change and asking for confirmation.
+func restoreBackup(path, name string) error {
+	restored, err := tasks().RestoreFromBackup(path, name, func(current, backup *tracker.TaskList) bool {
+		fmt.Printf("Backup %s has %d tasks (%d in trash); the current file has %d (%d in trash).\n",
+			name, len(backup.Live()), len(backup.Trash()), len(current.Live()), len(current.Trash()))
+		fmt.Println("Restoring it will change:", tracker.SummarizeChanges(tracker.DiffTaskLists(current, backup)))
+		return confirm("Restore this backup?")
+	})
+	if err != nil {
+		return err
+	}
+	if !restored {
+		fmt.Println("Nothing restored.")
+		return nil
+	}
+	fmt.Printf("Tasks restored from backup %s\n", name)
+	return nil
+}
+
+// runDoctor checks the task file at path and prints the problems found
+// and, with fix, the repairs made.
+func runDoctor(path string, fix bool) error {
+	report, err := tasks().Doctor(path, fix)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Problems) == 0 {
+		fmt.Printf("No problems found in %s (%d tasks)\n", path, report.Tasks)
+		return nil
+	}
+	fmt.Printf("Found %d problem(s) in %s:\n", len(report.Problems), path)
+	for _, p := range report.Problems {
+		fmt.Println("  " + p.String())
+	}
+	if !fix {
+		fmt.Println("Run task-cli doctor --fix to repair them.")
+		return nil
+	}
+	if report.Backup != "" {
+		fmt.Println("Backup written to", report.Backup)
+	}
+	for _, r := range report.Repairs {
+		fmt.Println("Fixed: " + r)
+	}
+	return nil
+}
+
+// listProjects prints every project with its number of open tasks, marking
+// the current one.
+func listProjects(projects tracker.Projects, cfg tracker.Config) error {
+	infos, err := tracker.ListProjects(projects, cfg)
+	if err != nil {
+		return err
+	}
+	for _, p := range infos {
+		marker := " "
+		if p.Current {
+			marker = "*"
+		}
+		fmt.Printf("%s %s (%d tasks)\n", marker, p.Name, p.Tasks)
+	}
+	return nil
+}
+
+// moveTask moves the task with the given ID to the target project.
+func moveTask(id string, target tracker.TaskStore, targetName string) error {
+	taskID, err := tracker.ParseID(id)
+	if err != nil {
+		return err
+	}
+	moved, err := tasks().MoveTask(taskID, target, targetName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Task (ID: %d) moved to project %s (new ID: %d)\n", taskID, targetName, moved.ID)
+	return nil
+}
+
+// listAllProjects prints the tasks query selects in every project,
+// prefixing each with its project name.
+func listAllProjects(projects tracker.Projects, cfg tracker.Config, query tracker.Query) error {
+	found, err := tracker.ListAllProjects(projects, cfg, query)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	if len(found) == 0 {
+		fmt.Fprintln(out, "No tasks found.")
+		return nil
+	}
+	now := tasks().Now()
+	for _, t := range found {
+		fmt.Fprintf(out, "[%s] %s\n", t.Project, formatTask(t.Task, now))
+	}
+	return nil
+}
+
+// printSyncResult reports what a sync changed on each side.
+func printSyncResult(result tracker.SyncResult, remotePath string) {
+	local, remote := tracker.VisibleChanges(result.Local), tracker.VisibleChanges(result.Remote)
+	if len(local) == 0 && len(remote) == 0 {
+		fmt.Println("Already in sync")
+		return
+	}
+	fmt.Println("Here:", tracker.SummarizeChanges(local))
+	fmt.Printf("%s: %s\n", remotePath, tracker.SummarizeChanges(remote))
+}
+
+// runMergeDriver merges the task files base, ours and theirs as a git merge
+// driver: the result is written to ours, renumbered tasks and resolved
+// fields are reported, and an error is returned if there are conflicts, so
+// that git marks the file as conflicted.
+func runMergeDriver(basePath, oursPath, theirsPath string, enc *tracker.Encryption) error {
+	result, err := tracker.MergeFiles(basePath, oursPath, theirsPath, enc)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range result.Renumbered {
+		fmt.Fprintf(os.Stderr, "task-cli merge: task %d (%s) added on both sides; theirs is now task %d\n", c.OldID, c.Description, c.NewID)
+	}
+	for _, r := range result.Resolved {
+		fmt.Fprintln(os.Stderr, "task-cli merge:", r)
+	}
+	for _, c := range result.Conflicts {
+		fmt.Fprintln(os.Stderr, "task-cli merge: CONFLICT:", c)
+	}
+	if len(result.Conflicts) > 0 {
+		return fmt.Errorf("%d conflicts; check the tasks above and mark the file resolved with git add", len(result.Conflicts))
+	}
+	return nil
+}
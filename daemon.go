@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
+)
+
+// daemonCommands are the commands the CLI hands to a running daemon. The
+// others ask questions on the terminal, work on other task files or change
+// how the task file is stored, and always run in the CLI.
+var daemonCommands = map[string]bool{
+	"add":    true,
+	"update": true,
+	"delete": true,
+	"mark":   true,
+	"reopen": true,
+	"tag":    true,
+	"tags":   true,
+	"list":   true,
+	"trash":  true,
+	"undo":   true,
+	"redo":   true,
+}
+
+// daemonConflicts are the commands that move or replace the task file, or
+// change how it is read. A daemon serving the file would go on with a
+// store that no longer matches it, so they are refused while one runs.
+var daemonConflicts = map[string]bool{
+	"migrate-storage": true,
+	"encrypt":         true,
+	"decrypt":         true,
+}
+
+// daemonTimeout bounds how long a command run by the daemon may take,
+// including the wait for the lock.
+const daemonTimeout = time.Minute
+
+// daemonRequest is what the CLI sends the daemon: a command line without
+// the global flags with the settings of the CLI, or a request to stop.
+type daemonRequest struct {
+	Args     []string        `json:"args,omitempty"`
+	Settings json.RawMessage `json:"settings,omitempty"`
+	Stop     bool            `json:"stop,omitempty"`
+}
+
+// daemonSettings are what a command depends on besides its arguments and
+// the task file: the config, as changed by the environment, and the time
+// zone dates are read and shown in. The daemon reads them when it starts,
+// so it only runs the commands of CLIs with the same settings.
+type daemonSettings struct {
+	Config      tracker.Config `json:"config"`
+	LockTimeout time.Duration  `json:"lockTimeout"`
+	TimeZone    string         `json:"timeZone"`
+}
+
+// currentSettings returns the settings of this process with the config cfg,
+// encoded for a daemonRequest.
+func currentSettings(cfg tracker.Config) json.RawMessage {
+	data, _ := json.Marshal(daemonSettings{Config: cfg, LockTimeout: cfg.LockTimeout, TimeZone: os.Getenv("TZ")})
+	return data
+}
+
+// daemonResponse is what the command printed while the daemon ran it.
+type daemonResponse struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+// socketPath is where the daemon serving the task file at path listens.
+func socketPath(path string) string {
+	return path + ".sock"
+}
+
+// forwardable reports whether the command line args may be run by the
+// daemon.
+func forwardable(args []string) bool {
+	command := args[0]
+	if strings.HasPrefix(command, "mark-") {
+		command = "mark"
+	}
+	if !daemonCommands[command] {
+		return false
+	}
+	for _, arg := range args {
+		if arg == "--all-projects" {
+			return false
+		}
+	}
+	return true
+}
+
+// dialDaemon connects to the daemon serving the task file at path.
+func dialDaemon(path string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", socketPath(path), time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(daemonTimeout))
+	return conn, nil
+}
+
+// daemonRunning reports whether a daemon serves the task file at path.
+func daemonRunning(path string) bool {
+	conn, err := dialDaemon(path)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// projectServed reports whether a daemon serves the task file of the named
+// project.
+func projectServed(projects tracker.Projects, name string) bool {
+	path, err := projects.Path(name)
+	return err == nil && daemonRunning(path)
+}
+
+// forwardToDaemon runs the command line args in the daemon serving the task
+// file at path and writes what it printed to stdout and stderr. settings are
+// those of the CLI; see currentSettings. It returns false if no daemon is
+// running, so that the command can run here instead.
+func forwardToDaemon(path string, settings json.RawMessage, args []string, stdout, stderr io.Writer) bool {
+	conn, err := dialDaemon(path)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Args: args, Settings: settings}); err != nil {
+		return false
+	}
+
+	// The command may have run even without an answer, so it must not run
+	// again here
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		fmt.Fprintln(stdout, "Error: no answer from the daemon:", err)
+		return true
+	}
+	io.WriteString(stdout, resp.Stdout)
+	io.WriteString(stderr, resp.Stderr)
+	return true
+}
+
+// StopDaemon stops the daemon serving the task file at path.
+func StopDaemon(path string) error {
+	conn, err := dialDaemon(path)
+	if err != nil {
+		return fmt.Errorf("no daemon is serving %s", path)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Stop: true}); err != nil {
+		return err
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	fmt.Print(resp.Stdout)
+	return nil
+}
+
+// ListenDaemon opens the socket of the daemon serving the task file at
+// path, next to the file. Only the user may connect to it.
+func ListenDaemon(path string) (net.Listener, error) {
+	sock := socketPath(path)
+	if conn, err := dialDaemon(path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already serving %s", path)
+	}
+	// A socket left behind by a daemon that was killed
+	os.Remove(sock)
+	listener, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// ServeDaemon serves the commands the CLI forwards for the task file at path
+// (see daemonCommands) on listener, until it is stopped with StopDaemon or
+// an interrupt. Commands from CLIs whose settings differ from settings, the
+// daemon's own, are refused. run runs a command line against the store as
+// the CLI does.
+// Commands run one at a time, so the daemon is the only writer among its
+// clients; the store keeps the tasks in memory for as long as no other
+// process changes the file.
+func ServeDaemon(listener net.Listener, path string, settings json.RawMessage, run func(args []string)) error {
+	defer listener.Close()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	// Let the command being run finish before returning
+	var running sync.WaitGroup
+	defer running.Wait()
+	var mu sync.Mutex
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(daemonTimeout))
+			var req daemonRequest
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+			if req.Stop {
+				json.NewEncoder(conn).Encode(daemonResponse{Stdout: "Daemon for " + path + " stopped\n"})
+				listener.Close()
+				return
+			}
+			if len(req.Args) == 0 || !forwardable(req.Args) {
+				json.NewEncoder(conn).Encode(daemonResponse{Stderr: "The daemon does not run this command\n"})
+				return
+			}
+			if !bytes.Equal(req.Settings, settings) {
+				json.NewEncoder(conn).Encode(daemonResponse{Stderr: "Error: the daemon serving " + path + " was started with another config, TASK_CLI_LOCK_TIMEOUT or TZ; restart it with task-cli daemon stop and task-cli daemon\n"})
+				return
+			}
+
+			mu.Lock()
+			stdout, stderr := captureOutput(func() {
+				run(req.Args)
+			})
+			mu.Unlock()
+			json.NewEncoder(conn).Encode(daemonResponse{Stdout: stdout, Stderr: stderr})
+		}()
+	}
+}
+
+// runDaemon runs the daemon command: it serves the task file of env until
+// stopped.
+func runDaemon(env commandEnv) {
+	// Load the tasks now, so that an encrypted file asks for its passphrase
+	// here rather than in the first command
+	if _, err := store.Load(); err != nil {
+		fmt.Println("Error starting daemon:", err)
+		return
+	}
+	listener, err := ListenDaemon(env.taskPath)
+	if err != nil {
+		fmt.Println("Error starting daemon:", err)
+		return
+	}
+
+	fmt.Printf("Serving %s on %s; stop with Ctrl-C or task-cli daemon stop\n", env.taskPath, socketPath(env.taskPath))
+	err = ServeDaemon(listener, env.taskPath, currentSettings(env.cfg), func(args []string) {
+		runCommand(args, env)
+	})
+	if err != nil {
+		fmt.Println("Error running daemon:", err)
+		return
+	}
+	fmt.Println("Daemon stopped")
+}
+
+// captureOutput runs fn with os.Stdout and os.Stderr redirected and returns
+// what it printed to each, with tracker.Notices counting as stderr. It must
+// not run concurrently with other output.
+func captureOutput(fn func()) (string, string) {
+	var outs [2]bytes.Buffer
+	var files [2]*os.File
+	var copying sync.WaitGroup
+	for i := range outs {
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, f := range files[:i] {
+				f.Close()
+			}
+			copying.Wait()
+			return "", fmt.Sprintln("Error:", err)
+		}
+		files[i] = w
+		copying.Add(1)
+		go func(i int) {
+			defer copying.Done()
+			io.Copy(&outs[i], r)
+			r.Close()
+		}(i)
+	}
+
+	stdout, stderr, notices := os.Stdout, os.Stderr, tracker.Notices
+	os.Stdout, os.Stderr, tracker.Notices = files[0], files[1], files[1]
+	defer func() {
+		os.Stdout, os.Stderr, tracker.Notices = stdout, stderr, notices
+	}()
+	fn()
+	files[0].Close()
+	files[1].Close()
+	copying.Wait()
+	return outs[0].String(), outs[1].String()
+}
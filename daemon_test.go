@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
+)
+
+// TestDaemon tests serving commands over the daemon's socket.
+//
+// The test includes the following cases:
+//
+//  1. No daemon: Forward a command for a task file no daemon serves. The
+//     test checks that the command is left to the CLI.
+//
+//  2. Forwarding: Forward an add and a list to a running daemon. The test
+//     checks that the daemon ran them against its store and that their
+//     output was passed back.
+//
+//  3. Other commands: Send a command the daemon does not run. The test checks
+//     that it is refused.
+//
+//  4. Other settings: Forward an add from a CLI with another lock timeout
+//     and another subtask policy. The test checks that it is refused.
+//
+//  5. No history: Undo against the daemon's store, which keeps no history.
+//     The test checks that an error is printed rather than the daemon
+//     crashing.
+//
+//  6. Replacing the file: Migrate the storage of the served task file. The
+//     test checks that it is refused while the daemon runs.
+//
+//  7. Notices: The test checks that notices are passed back with the output
+//     of the command.
+//
+//  8. Stop: Stop the daemon. The test checks that it returns and removes its
+//     socket.
+func TestDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	SetStore(tracker.NewMemoryStore())
+	defer SetStore(tracker.NewJSONFileStore("tasks.json"))
+	settings := currentSettings(tracker.Config{})
+	forward := func(args ...string) (bool, string) {
+		var out strings.Builder
+		forwarded := forwardToDaemon(path, settings, args, &out, &out)
+		return forwarded, out.String()
+	}
+
+	// Case 1: Tidak ada daemon
+	if forwarded, _ := forward("list"); forwarded {
+		t.Fatal("Expected the command to run in the CLI")
+	}
+
+	// Case 2: Perintah diteruskan ke daemon
+	listener, err := ListenDaemon(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	served := make(chan error)
+	go func() {
+		served <- ServeDaemon(listener, path, currentSettings(tracker.Config{}), func(args []string) {
+			runCommand(args, commandEnv{})
+		})
+	}()
+	if forwarded, out := forward("add", "Served"); !forwarded || !strings.Contains(out, "Task added successfully (ID: 1)") {
+		t.Fatalf("Expected the daemon to add the task, got %v %q", forwarded, out)
+	}
+	if _, out := forward("list"); !strings.Contains(out, "Description: Served") {
+		t.Errorf("Expected the task in the list, got %q", out)
+	}
+	if task, err := store.Get(1); err != nil || task.Description != "Served" {
+		t.Errorf("Expected the task in the daemon's store, got %+v (err %v)", task, err)
+	}
+
+	// Case 3: Perintah yang tidak dilayani daemon
+	if _, out := forward("sync", "other.json"); !strings.Contains(out, "does not run") {
+		t.Errorf("Expected sync to be refused, got %q", out)
+	}
+
+	// Case 4: Pengaturan yang berbeda
+	for _, cfg := range []tracker.Config{{LockTimeout: time.Minute}, {Subtasks: tracker.ChildrenCascade}} {
+		settings = currentSettings(cfg)
+		if _, out := forward("add", "Elsewhere"); !strings.Contains(out, "another config") {
+			t.Errorf("Expected the command to be refused, got %q", out)
+		}
+	}
+	settings = currentSettings(tracker.Config{})
+	if _, err := store.Get(2); err == nil {
+		t.Error("Expected no task added with other settings")
+	}
+
+	// Case 5: Store tanpa history
+	if _, out := forward("undo"); !strings.Contains(out, "keeps no history") {
+		t.Errorf("Expected undo to be refused, got %q", out)
+	}
+
+	// Case 6: Perintah yang mengganti file
+	if out, _ := captureOutput(func() { runCommand([]string{"migrate-storage", "dir"}, commandEnv{taskPath: path}) }); !strings.Contains(out, "a daemon is serving") {
+		t.Errorf("Expected migrate-storage to be refused, got %q", out)
+	}
+	if _, err := os.Stat(tracker.NewDirStore(path).Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected no task directory, got %v", err)
+	}
+
+	// Case 7: Notice
+	if _, stderr := captureOutput(func() { fmt.Fprintln(tracker.Notices, "Upgraded") }); stderr != "Upgraded\n" {
+		t.Errorf("Expected the notice on stderr, got %q", stderr)
+	}
+
+	// Case 8: Hentikan daemon
+	if err := StopDaemon(path); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the daemon to stop")
+	}
+	if _, err := os.Stat(socketPath(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed, got %v", err)
+	}
+}
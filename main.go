@@ -1,146 +1,1079 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
 )
 
 // main is the entry point of the command-line interface of the task tracker.
 //
 // The supported commands are:
 //
-//   - add: Adds a new task with the given description.
+//   - add: Adds a new task with the given description and, optionally, a
+//     priority: none (the default), low, medium, high or critical, and a
+//     due date such as 2024-05-31, tomorrow, fri or "in 3 days"; see
+//     tracker.ParseDue.
+//
+//     Tags are given as +tag after the task name, which may start with "+"
+//     itself. --parent makes the task a subtask of the task with the given
+//     ID.
 //
-//     Usage: task-cli add <task-name>
+//     Usage: task-cli add <task-name> [--priority <priority>] [--due <when>] [--parent <id>] [+<tag>...]
 //
-//   - update: Updates the task with the given ID and description.
+//   - update: Updates the description, the priority, the due date and/or
+//     the parent of the task with the given ID. --due none removes the due
+//     date and --parent none makes the task a top-level task.
 //
-//     Usage: task-cli update <id_task> <description>
+//     Usage: task-cli update <id_task> [<description>] [--priority <priority>] [--due <when>|none] [--parent <id>|none]
 //
-//   - delete: Deletes the task with the given ID.
+//   - delete: Moves the task with the given ID to the trash. A task with
+//     subtasks is not deleted unless the config file says to delete them
+//     along with it or to detach them; see tracker.ChildPolicy.
 //
 //     Usage: task-cli delete <id_task>
 //
-//   - mark-in-progress: Marks the task with the given ID as in-progress.
+//   - mark: Marks the task with the given ID with a status of the workflow,
+//     todo, in-progress and done unless the config file declares others; see
+//     tracker.Workflow. mark-<status>, e.g. mark-done, is short for it.
+//     Closing a task with open subtasks follows the same subtask setting as
+//     delete.
+//
+//     Usage: task-cli mark <status> <task_id> || task-cli mark-<status> <task_id>
+//
+//   - tag: Adds the tags given as +tag to the task with the given ID and
+//     removes those given as -tag. Tags are stored in lower case.
+//
+//     Usage: task-cli tag <task_id> [+<tag>...] [-<tag>...]
+//
+//   - tags: Lists every tag with the number of open and done tasks that have
+//     it, or renames a tag on every task in one change.
+//
+//     Usage: task-cli tags || task-cli tags rename <old> <new>
+//
+//   - reopen: Moves the task with the given ID back to the first status of
+//     the workflow, todo by default. A done task has to be reopened before it
+//     can be marked as in-progress again; see tracker.Workflow for the
+//     allowed transitions.
+//
+//     Usage: task-cli reopen <task_id>
+//
+//   - list: Lists all tasks with the given status, or those whose status is
+//     open or closed in the workflow, most important first, with their due
+//     dates and tags; overdue tasks are flagged. +tag limits the list to the
+//     tasks with the tag and +a,b to those with a or b; every +tag argument
+//     applies. -tag leaves out the tasks with the tag. --priority limits the
+//     list to the given priorities, --overdue to the overdue tasks and
+//     --due-before to the tasks due before a day. --sort id keeps the file
+//     order and --sort due puts the tasks due first first. Subtasks are
+//     indented under their parent, which shows how many of them are done.
+//     With --all-projects it lists the tasks of every project, prefixed with
+//     the project name.
+//
+//     Usage: task-cli list [<status>|open|closed] [+<tag>[,<tag>...]...] [-<tag>[,<tag>...]...] [--priority <priority>[,<priority>...]] [--overdue] [--due-before <when>] [--sort priority|id|due] [--all-projects]
+//
+//   - project: Manages named projects, each with its own task list.
+//
+//     Usage: task-cli project add <name> || task-cli project list || task-cli project rename <old> <new> || task-cli project remove <name> [--force] || task-cli project use <name>
+//
+//   - move: Moves a task to another project, keeping its status and
+//     timestamps.
+//
+//     Usage: task-cli move <id_task> <project>
+//
+//   - where: Prints which task file is used and why.
+//
+//     Usage: task-cli where
+//
+//   - compact: Folds the journal into the snapshot (journal storage only).
+//
+//     Usage: task-cli compact
+//
+//   - trash: Lists the trash, restores a task from it with its original ID,
+//     or removes tasks from it for good.
+//
+//     Usage: task-cli trash list || task-cli trash restore <id_task> || task-cli trash purge [--older-than 30d]
 //
-//     Usage: task-cli mark-in-progress <task_id>
+//   - backup: Lists the rolling backups of the task file with their task
+//     counts, or restores one after showing what it would change. Backups are
+//     written when enabled in the config; see tracker.BackupPolicy.
 //
-//   - mark-done: Marks the task with the given ID as done.
+//     Usage: task-cli backup list || task-cli backup restore <name>
 //
-//     Usage: task-cli mark-done <task_id>
+//   - doctor: Reports integrity problems in the task file such as duplicate
+//     IDs or unknown statuses. With --fix it backs the file up and repairs
+//     them.
 //
-//   - list: Lists all tasks with the given status.
+//     Usage: task-cli doctor [--fix]
 //
-//     Usage: task-cli list || task-cli list todo || task-cli list in-progress || task-cli list done
+//   - encrypt: Encrypts the task file, its backups and its history with a
+//     passphrase taken from TASK_CLI_PASSPHRASE or asked for on the
+//     terminal. Encrypted files are read and written transparently.
+//
+//     Usage: task-cli encrypt
+//
+//   - decrypt: Turns an encrypted task file back into plain JSON.
+//
+//     Usage: task-cli decrypt
+//
+//   - migrate-storage: Moves the tasks from the task file into a directory
+//     with one file per task, or back. Select the layout with the storage
+//     setting of the config file.
+//
+//     Usage: task-cli migrate-storage json|dir
+//
+//   - merge-driver: Merges three versions of a task file by task ID, for
+//     use as a git merge driver, writing the result over ours and exiting
+//     with an error on delete/modify conflicts. "merge-driver install"
+//     registers it for the task file in .gitattributes and the git config.
+//
+//     Usage: task-cli merge-driver <base> <ours> <theirs> || task-cli merge-driver install
+//
+//   - sync: Reconciles the tasks with another task file, matching tasks
+//     by UID. Tasks missing on one side are copied over, removals are
+//     carried over through tombstones, and tasks changed on both sides
+//     keep the latest change, or the one picked with --interactive.
+//
+//     Usage: task-cli sync <other-file> [--interactive]
+//
+//   - undo, redo: Reverts or reapplies the last changes made by add, update,
+//     delete, the mark commands, reopen, tag and tags rename.
+//
+//     Usage: task-cli undo [steps] || task-cli redo [steps]
+//
+//   - daemon: Serves add, update, delete, the mark commands, reopen, tag,
+//     tags, list, trash, undo and redo for the task file over a Unix socket
+//     next to it, keeping the tasks in memory. While it runs, the CLI hands
+//     these commands to it; otherwise they run in the CLI. "daemon stop"
+//     stops it.
+//
+//     Usage: task-cli daemon || task-cli daemon stop
+//
+// The task file is chosen by the global --file flag, the TASK_CLI_FILE
+// environment variable, a .tasks.json in the working directory or one of its
+// parents, or else $XDG_DATA_HOME/task-cli/tasks.json, in that order. See
+// tracker.ResolveTaskFile. The global --project flag picks the project to
+// work on instead of the one selected with "project use"; see
+// tracker.Projects. The config file (see tracker.ConfigPath) selects how the
+// tasks are stored and declares the statuses; see tracker.Config.
+//
+// The commands are a thin layer over the tracker package, which does the
+// work and leaves the printing to them.
+//
+// Commands that change the task file lock it first, waiting up to five seconds
+// for other task-cli processes. Set TASK_CLI_LOCK_TIMEOUT (e.g. "30s") to
+// change the wait.
 //
 // The program prints an error message and returns if any of the commands is
 // called with the wrong number of arguments.
 //
 // The program exits with an error code if any of the commands fails.
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: task-cli [command] [arguments]")
+	flags, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
 
-	command := os.Args[1]
+	if len(args) < 1 {
+		fmt.Println("Usage: task-cli [--file <path>] [--project <name>] [command] [arguments]")
+		return
+	}
+
+	command := args[0]
+
+	if command == "merge-driver" && len(args) == 4 {
+		// Run as a git merge driver; the files come from git, so no task
+		// file is resolved
+		enc := &tracker.Encryption{Passphrase: tracker.PassphrasePrompt(false)}
+		if err := runMergeDriver(args[1], args[2], args[3], enc); err != nil {
+			fmt.Fprintln(os.Stderr, "task-cli merge:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Work out which task file to use
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	location, err := tracker.ResolveTaskFile(flags.file, cwd)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Read the config file
+	cfg, err := tracker.LoadConfig()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	// Pick the project: the --project flag, else the one selected with
+	// "project use"
+	projects := tracker.Projects{DefaultPath: location.Path}
+	project := flags.project
+	if project == "" {
+		project = projects.Current()
+	}
+	taskPath, err := projects.Path(project)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if !projects.Exists(project) {
+		fmt.Printf("Error: project %q not found\n", project)
+		return
+	}
+
+	taskStore, err := tracker.OpenStore(taskPath, cfg)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	SetStore(taskStore)
+	SetWorkflow(cfg.Statuses)
+	SetSubtaskPolicy(cfg.Subtasks)
+	warnStorage(taskPath, taskStore)
+
+	if command == "where" {
+		// Print the resolved task file and why it was chosen
+		printLocation(location, cwd)
+		if project != tracker.DefaultProject {
+			fmt.Printf("Project: %s (%s)\n", project, taskPath)
+		}
+		switch base := tracker.BaseStore(taskStore).(type) {
+		case *tracker.JournalStore:
+			fmt.Println("Journal:", base.Path)
+		case *tracker.DirStore:
+			fmt.Println("Task directory:", base.Dir)
+		}
+		if daemonRunning(taskPath) {
+			fmt.Println("Daemon:", socketPath(taskPath))
+		}
+		return
+	}
+
+	env := commandEnv{projects: projects, project: project, cfg: cfg, taskPath: taskPath}
+	switch {
+	case command == "daemon":
+		// Serve the commands for the task file until stopped.
+		switch {
+		case len(args) == 1:
+			if recoverTaskFile() {
+				runDaemon(env)
+			}
+		case len(args) == 2 && args[1] == "stop":
+			if err := StopDaemon(taskPath); err != nil {
+				fmt.Println("Error stopping daemon:", err)
+			}
+		default:
+			fmt.Println("Usage: task-cli daemon || task-cli daemon stop")
+		}
+		return
+	case forwardable(args) && forwardToDaemon(taskPath, currentSettings(cfg), args, os.Stdout, os.Stderr):
+		// The daemon serving the task file ran the command
+		return
+	}
+
+	// Offer to roll back a task file damaged by an interrupted write. Doctor
+	// reports the damage itself
+	if command != "doctor" && !recoverTaskFile() {
+		return
+	}
+	runCommand(args, env)
+}
+
+// commandEnv is what the commands need besides their arguments and the
+// store.
+type commandEnv struct {
+	projects tracker.Projects
+	project  string
+	cfg      tracker.Config
+	taskPath string
+}
+
+// runCommand runs the command in args (see main) against the store,
+// printing its results and errors.
+func runCommand(args []string, env commandEnv) {
+	command := args[0]
+	projects, project, cfg, taskPath := env.projects, env.project, env.cfg, env.taskPath
+	var err error
+	if daemonConflicts[command] && daemonRunning(taskPath) {
+		fmt.Printf("Error: a daemon is serving %s; stop it with task-cli daemon stop before running %s\n", taskPath, command)
+		return
+	}
 
 	switch command {
 	case "add":
-		// Add a new task with the given description.
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: task-cli add <task-name>")
+		// Add a new task with the given description and priority, possibly
+		// as a subtask of another.
+		usage := "Usage: task-cli add <task-name> [--priority <priority>] [--due <when>] [--parent <id>] [+<tag>...]"
+		fields, rest, err := parseTaskFlags(args[1:], tasks().Now())
+		if err != nil {
+			fmt.Println("Error adding task:", err)
 			return
 		}
-		taskDescription := os.Args[2]
-		err := AddTask(taskDescription)
-		if err != nil {
+		if len(rest) < 1 {
+			fmt.Println(usage)
+			return
+		}
+		// Only the arguments after the description are tags, so it may
+		// start with "+" itself
+		tags, _, extra := takeTags(rest[1:], false)
+		if len(extra) > 0 {
+			fmt.Println(usage)
+			return
+		}
+		draft := tracker.Task{Description: rest[0], Tags: tags}
+		if fields.Priority != nil {
+			draft.Priority = *fields.Priority
+		}
+		if fields.DueAt != nil && !fields.DueAt.IsZero() {
+			draft.DueAt = fields.DueAt
+		}
+		if fields.ParentID != nil {
+			draft.ParentID = *fields.ParentID
+		}
+		if err := addTask(draft); err != nil {
 			fmt.Println("Error adding task:", err)
 		}
 
 	case "update":
-		// Update the task with the given ID and description.
-		if len(os.Args) < 4 {
-			fmt.Println("Usage: task-cli update <id_task> <description>")
+		// Update the description, priority, due date and/or parent of the
+		// task with the given ID.
+		usage := "Usage: task-cli update <id_task> [<description>] [--priority <priority>] [--due <when>|none] [--parent <id>|none]"
+		update, rest, err := parseTaskFlags(args[1:], tasks().Now())
+		if err != nil {
+			fmt.Println("Error updating task:", err)
 			return
 		}
-		taskID := os.Args[2]
-		taskDescription := os.Args[3]
-		err := UpdateTask(taskID, taskDescription)
-		if err != nil {
+		if len(rest) >= 2 {
+			update.Description = &rest[1]
+		}
+		if len(rest) < 1 || len(rest) > 2 || update == (tracker.TaskUpdate{}) {
+			fmt.Println(usage)
+			return
+		}
+		if err := updateTask(rest[0], update); err != nil {
 			fmt.Println("Error updating task:", err)
 		}
 
 	case "delete":
 		// Delete the task with the given ID.
-		if len(os.Args) != 3 {
+		if len(args) != 2 {
 			fmt.Println("Usage: task-cli delete <id_task>")
 			return
 		}
-		taskID := os.Args[2]
+		taskID := args[1]
 		err := DeleteTask(taskID)
 		if err != nil {
 			fmt.Println("Error deleting task:", err)
 		}
 
-	case "mark-in-progress":
-		// Mark the task with the given ID as in-progress.
-		if len(os.Args) != 3 {
-			fmt.Println("Usage: task-cli mark-in-progress <task_id>")
+	case "mark":
+		// Mark the task with the given ID with a status of the workflow.
+		if len(args) != 3 {
+			fmt.Printf("Usage: task-cli mark <%s> <task_id>\n", statusUsage())
 			return
 		}
-		taskID := os.Args[2]
-		err := MarkTask(taskID, "in-progress")
-		if err != nil {
-			fmt.Println("Error marking task as in-progress:", err)
+		if err := MarkTask(args[2], args[1]); err != nil {
+			fmt.Printf("Error marking task as %s: %v\n", args[1], err)
 		}
 
-	case "mark-done":
-		// Mark the task with the given ID as done.
-		if len(os.Args) != 3 {
-			fmt.Println("Usage: task-cli mark-done <task_id>")
+	case "tag":
+		// Add and remove tags of the task with the given ID.
+		usage := "Usage: task-cli tag <task_id> [+<tag>...] [-<tag>...]"
+		add, remove, rest := takeTags(args[1:], true)
+		if len(rest) != 1 || len(add)+len(remove) == 0 {
+			fmt.Println(usage)
+			return
+		}
+		if err := TagTask(rest[0], add, remove); err != nil {
+			fmt.Println("Error tagging task:", err)
+		}
+
+	case "tags":
+		// List the tags with their task counts, or rename a tag.
+		switch {
+		case len(args) == 1:
+			err = ListTags()
+		case len(args) == 4 && args[1] == "rename":
+			err = RenameTag(args[2], args[3])
+		default:
+			fmt.Println("Usage: task-cli tags || task-cli tags rename <old> <new>")
 			return
 		}
-		taskID := os.Args[2]
-		err := MarkTask(taskID, "done")
 		if err != nil {
-			fmt.Println("Error marking task as done:", err)
+			fmt.Println("Error:", err)
+		}
+
+	case "reopen":
+		// Move the task with the given ID back to todo.
+		if len(args) != 2 {
+			fmt.Println("Usage: task-cli reopen <task_id>")
+			return
+		}
+		if err := ReopenTask(args[1]); err != nil {
+			fmt.Println("Error reopening task:", err)
 		}
 
 	case "list":
-		// List all tasks with the given status.
-		if len(os.Args) < 2 || len(os.Args) > 3 {
-			fmt.Println("Usage: task-cli list || task-cli list todo || task-cli list in-progress || task-cli list done")
+		// List all tasks with the given status of the workflow, or the
+		// open or closed ones, most important first, in the current
+		// project or, with --all-projects, in every project.
+		usage := fmt.Sprintf("Usage: task-cli list [%s|open|closed] [+<tag>[,<tag>...]...] [-<tag>[,<tag>...]...] [--priority <priority>[,<priority>...]] [--overdue] [--due-before <when>] [--sort priority|id|due] [--all-projects]", statusUsage())
+		options, err := parseListArgs(args[1:], tasks().Now())
+		if err != nil {
+			fmt.Println("Error:", err)
+			fmt.Println(usage)
 			return
 		}
 
-		if len(os.Args) == 2 {
-			err := ListTasks("all")
-			if err != nil {
+		if options.allProjects {
+			err = listAllProjects(projects, cfg, options.query)
+		} else {
+			err = listTasks(options.query)
+		}
+		if err != nil {
+			if options.status == "all" {
 				fmt.Println("Error listing all tasks:", err)
+			} else {
+				fmt.Printf("Error listing %s tasks: %v\n", options.status, err)
+			}
+		}
+
+	case "project":
+		// Manage the named projects.
+		usage := "Usage: task-cli project add <name> || task-cli project list || task-cli project rename <old> <new> || task-cli project remove <name> [--force] || task-cli project use <name>"
+		if len(args) < 2 {
+			fmt.Println(usage)
+			return
+		}
+		switch {
+		case (args[1] == "rename" || args[1] == "remove") && len(args) >= 3 && projectServed(projects, args[2]):
+			err = fmt.Errorf("a daemon is serving project %s; stop it with task-cli daemon stop first", args[2])
+		case args[1] == "list" && len(args) == 2:
+			err = listProjects(projects, cfg)
+		case args[1] == "add" && len(args) == 3:
+			if err = projects.Add(args[2]); err == nil {
+				fmt.Printf("Project %s added\n", args[2])
+			}
+		case args[1] == "rename" && len(args) == 4:
+			if err = projects.Rename(args[2], args[3]); err == nil {
+				fmt.Printf("Project %s renamed to %s\n", args[2], args[3])
+			}
+		case args[1] == "remove" && len(args) == 3:
+			if err = tracker.RemoveProject(projects, cfg, args[2], false); err == nil {
+				fmt.Printf("Project %s removed\n", args[2])
+			}
+		case args[1] == "remove" && len(args) == 4 && args[3] == "--force":
+			if err = tracker.RemoveProject(projects, cfg, args[2], true); err == nil {
+				fmt.Printf("Project %s removed\n", args[2])
+			}
+		case args[1] == "use" && len(args) == 3:
+			if err = projects.Use(args[2]); err == nil {
+				fmt.Printf("Now using project %s\n", args[2])
+			}
+		default:
+			fmt.Println(usage)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error running project %s: %v\n", args[1], err)
+		}
+
+	case "move":
+		// Move a task to another project.
+		if len(args) != 3 {
+			fmt.Println("Usage: task-cli move <id_task> <project>")
+			return
+		}
+		targetPath, err := projects.Path(args[2])
+		if err != nil {
+			fmt.Println("Error moving task:", err)
+			return
+		}
+		if !projects.Exists(args[2]) {
+			fmt.Printf("Error moving task: project %q not found\n", args[2])
+			return
+		}
+		if args[2] == project {
+			fmt.Println("Error moving task: the task is already in project", project)
+			return
+		}
+		target, err := tracker.OpenStore(targetPath, cfg)
+		if err != nil {
+			fmt.Println("Error moving task:", err)
+			return
+		}
+		if err := moveTask(args[1], target, args[2]); err != nil {
+			fmt.Println("Error moving task:", err)
+		}
+
+	case "compact":
+		// Fold the journal into the snapshot.
+		if len(args) != 1 {
+			fmt.Println("Usage: task-cli compact")
+			return
+		}
+		journal, ok := tracker.BaseStore(store).(*tracker.JournalStore)
+		if !ok {
+			fmt.Println("Nothing to compact: compact only applies to journal storage")
+			return
+		}
+		n, err := journal.Compact()
+		if err != nil {
+			fmt.Println("Error compacting journal:", err)
+			return
+		}
+		fmt.Printf("Compacted %d journal events into %s\n", n, journal.Snapshot.Path)
+
+	case "trash":
+		// Inspect, restore and empty the trash.
+		usage := "Usage: task-cli trash list || task-cli trash restore <id_task> || task-cli trash purge [--older-than 30d]"
+		if len(args) < 2 {
+			fmt.Println(usage)
+			return
+		}
+		switch {
+		case args[1] == "list" && len(args) == 2:
+			if err := ListTrash(); err != nil {
+				fmt.Println("Error listing trash:", err)
+			}
+		case args[1] == "restore" && len(args) == 3:
+			if err := RestoreTask(args[2]); err != nil {
+				fmt.Println("Error restoring task:", err)
+			}
+		case args[1] == "purge" && len(args) == 2:
+			if err := PurgeTrash(0); err != nil {
+				fmt.Println("Error purging trash:", err)
+			}
+		case args[1] == "purge" && len(args) == 4 && args[2] == "--older-than":
+			age, err := tracker.ParseAge(args[3])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err := PurgeTrash(age); err != nil {
+				fmt.Println("Error purging trash:", err)
+			}
+		default:
+			fmt.Println(usage)
+		}
+
+	case "backup":
+		// Inspect and roll back to the rolling backups.
+		usage := "Usage: task-cli backup list || task-cli backup restore <name>"
+		switch {
+		case len(args) == 2 && args[1] == "list":
+			if err := listBackups(taskPath); err != nil {
+				fmt.Println("Error listing backups:", err)
+			}
+		case len(args) == 3 && args[1] == "restore":
+			if err := restoreBackup(taskPath, args[2]); err != nil {
+				fmt.Println("Error restoring backup:", err)
 			}
+		default:
+			fmt.Println(usage)
+		}
+
+	case "doctor":
+		// Check the task file for integrity problems, optionally fixing them.
+		if len(args) > 2 || (len(args) == 2 && args[1] != "--fix") {
+			fmt.Println("Usage: task-cli doctor [--fix]")
+			return
+		}
+		if err := runDoctor(taskPath, len(args) == 2); err != nil {
+			fmt.Println("Error checking task file:", err)
+		}
+
+	case "encrypt":
+		// Encrypt the task file with a new passphrase.
+		if len(args) != 1 {
+			fmt.Println("Usage: task-cli encrypt")
+			return
+		}
+		if fileStore := tracker.TaskFileStore(store); fileStore != nil {
+			fileStore.Encryption.Passphrase = tracker.PassphrasePrompt(true)
+		}
+		n, err := tracker.EncryptTaskFile(store)
+		if err != nil {
+			fmt.Println("Error encrypting task file:", err)
+			return
+		}
+		fmt.Printf("Task file encrypted (%d files)\n", n)
+
+	case "decrypt":
+		// Turn an encrypted task file back into plain JSON.
+		if len(args) != 1 {
+			fmt.Println("Usage: task-cli decrypt")
+			return
+		}
+		n, err := tracker.DecryptTaskFile(store)
+		if err != nil {
+			fmt.Println("Error decrypting task file:", err)
+			return
+		}
+		fmt.Printf("Task file decrypted (%d files)\n", n)
+
+	case "migrate-storage":
+		// Convert the task file between the single file and the directory
+		// layout.
+		if len(args) != 2 || (args[1] != tracker.StorageJSON && args[1] != tracker.StorageDir) {
+			fmt.Println("Usage: task-cli migrate-storage json|dir")
+			return
+		}
+		n, err := tracker.MigrateStorage(taskPath, args[1], cfg.LockTimeout)
+		if err != nil {
+			fmt.Println("Error migrating storage:", err)
+			return
+		}
+		if args[1] == tracker.StorageDir {
+			fmt.Printf("Moved %d tasks from %s to %s\n", n, taskPath, tracker.NewDirStore(taskPath).Dir)
 		} else {
-			if os.Args[2] == "todo" {
-				err := ListTasks("todo")
-				if err != nil {
-					fmt.Println("Error listing todo tasks:", err)
-				}
-			} else if os.Args[2] == "in-progress" {
-				err := ListTasks("in-progress")
-				if err != nil {
-					fmt.Println("Error listing in-progress tasks:", err)
-				}
-			} else if os.Args[2] == "done" {
-				err := ListTasks("done")
-				if err != nil {
-					fmt.Println("Error listing done tasks:", err)
-				}
+			fmt.Printf("Moved %d tasks from %s to %s\n", n, tracker.NewDirStore(taskPath).Dir, taskPath)
+		}
+		if cfg.Storage != args[1] {
+			path, _ := tracker.ConfigPath()
+			fmt.Printf("Set \"storage\": %q in %s to use it\n", args[1], path)
+		}
+
+	case "merge-driver":
+		// Register the merge driver for the task file with git.
+		if len(args) != 2 || args[1] != "install" {
+			fmt.Println("Usage: task-cli merge-driver <base> <ours> <theirs> || task-cli merge-driver install")
+			return
+		}
+		exe, err := os.Executable()
+		if err != nil {
+			fmt.Println("Error installing merge driver:", err)
+			return
+		}
+		rel, root, err := tracker.InstallMergeDriver(taskPath, exe)
+		if err != nil {
+			fmt.Println("Error installing merge driver:", err)
+			return
+		}
+		fmt.Printf("Merge driver installed for %s in %s\n", rel, root)
+
+	case "sync":
+		// Reconcile the tasks with another task file.
+		if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "--interactive") {
+			fmt.Println("Usage: task-cli sync <other-file> [--interactive]")
+			return
+		}
+		otherPath := args[1]
+		remote, err := tracker.OpenStore(otherPath, cfg)
+		if err != nil {
+			fmt.Println("Error syncing:", err)
+			return
+		}
+		if _, ok := tracker.BaseStore(remote).(*tracker.DirStore); !ok {
+			if _, err := os.Stat(otherPath); err != nil {
+				fmt.Println("Error syncing:", err)
+				return
+			}
+		}
+		prefer := tracker.PreferNewer
+		if len(args) == 3 {
+			prefer = func(local, remote tracker.Task) bool {
+				fmt.Println("Here:  ", formatTask(local, tasks().Now()))
+				fmt.Println("There: ", formatTask(remote, tasks().Now()))
+				return confirm("Keep the version from " + otherPath + "?")
+			}
+		}
+		result, err := tasks().Sync(taskPath, remote, otherPath, prefer)
+		if err != nil {
+			fmt.Println("Error syncing:", err)
+			return
+		}
+		printSyncResult(result, otherPath)
+
+	case "undo", "redo":
+		// Walk back or forth through the history of changes.
+		steps := 1
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Printf("Usage: task-cli %s [steps]\n", command)
+				return
+			}
+			steps = n
+		} else if len(args) > 2 {
+			fmt.Printf("Usage: task-cli %s [steps]\n", command)
+			return
+		}
+		history, ok := store.(*tracker.HistoryStore)
+		if !ok {
+			fmt.Printf("Error running %s: the task store keeps no history of changes\n", command)
+			return
+		}
+		walk, verb := history.Undo, "Undone"
+		if command == "redo" {
+			walk, verb = history.Redo, "Redone"
+		}
+		done, err := walk(steps)
+		for _, change := range done {
+			fmt.Printf("%s: %s\n", verb, change)
+		}
+		if err != nil {
+			fmt.Printf("Error running %s: %v\n", command, err)
+		}
+
+	default:
+		// mark-<status> is short for mark <status>, e.g. mark-done
+		status, ok := strings.CutPrefix(command, "mark-")
+		if !ok {
+			fmt.Println("Unknown command:", command)
+			return
+		}
+		if len(args) != 2 {
+			fmt.Printf("Usage: task-cli %s <task_id>\n", command)
+			return
+		}
+		if err := MarkTask(args[1], status); err != nil {
+			fmt.Printf("Error marking task as %s: %v\n", status, err)
+		}
+	}
+}
+
+// statusUsage lists the statuses of the workflow for usage messages, e.g.
+// "todo|in-progress|done".
+func statusUsage() string {
+	names := make([]string, len(workflow))
+	for i, status := range workflow.Statuses() {
+		names[i] = string(status)
+	}
+	return strings.Join(names, "|")
+}
+
+// recoverTaskFile checks that the task file can be loaded. If it is damaged
+// and a last good copy exists, the user is asked whether to restore it. It
+// reports whether the command should go ahead.
+func recoverTaskFile() bool {
+	// Load without repairs, so doctor still gets to see the problems
+	_, err := tracker.LoadUnrepaired(store)
+	var cerr *tracker.CorruptFileError
+	if !errors.As(err, &cerr) {
+		// Other errors are reported by the command itself
+		return true
+	}
+
+	fmt.Println("Error:", cerr)
+	restorer, ok := tracker.BaseStore(store).(interface{ RestoreBackup() error })
+	if cerr.Backup == "" || !ok {
+		fmt.Println("No good copy of the task file is available to restore.")
+		return false
+	}
+
+	if !confirm(fmt.Sprintf("Restore the last good copy from %s?", cerr.Backup)) {
+		fmt.Println("Task file left untouched.")
+		return false
+	}
+
+	if err := restorer.RestoreBackup(); err != nil {
+		fmt.Println("Error restoring task file:", err)
+		return false
+	}
+	fmt.Printf("Task file restored from %s (damaged copy kept as %s.corrupt)\n", cerr.Backup, cerr.Path)
+	return true
+}
+
+// warnStorage warns about tasks kept in a layout other than the configured
+// one, which the configured store would not see.
+func warnStorage(path string, s tracker.TaskStore) {
+	base := tracker.BaseStore(s)
+	if _, ok := base.(*tracker.JournalStore); !ok {
+		if _, err := os.Stat(path + ".journal"); err == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %s.journal holds changes that are not in the task file; set \"storage\": \"journal\" and run compact to keep them\n", path)
+		}
+	}
+	_, fileErr := os.Stat(path)
+	if dir, ok := base.(*tracker.DirStore); ok {
+		if fileErr == nil && !dir.Exists() {
+			fmt.Fprintf(os.Stderr, "Warning: the tasks are in %s but directory storage is configured; run migrate-storage dir to move them\n", path)
+		}
+	} else if dir := tracker.NewDirStore(path); os.IsNotExist(fileErr) && dir.Exists() {
+		fmt.Fprintf(os.Stderr, "Warning: the tasks are in the directory %s; set \"storage\": \"dir\" to use them\n", dir.Dir)
+	}
+}
+
+// globalFlags holds the flags accepted by every command.
+type globalFlags struct {
+	file    string
+	project string
+}
+
+// parseGlobalFlags removes the global flags from args, wherever they appear,
+// and returns them along with the remaining arguments. Arguments after "--"
+// are never treated as flags.
+func parseGlobalFlags(args []string) (globalFlags, []string, error) {
+	var flags globalFlags
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return flags, append(rest, args[i+1:]...), nil
+		case arg == "--file" || arg == "--project":
+			if i+1 >= len(args) {
+				return flags, nil, fmt.Errorf("flag %s needs a value", arg)
+			}
+			i++
+			if arg == "--file" {
+				flags.file = args[i]
 			} else {
-				fmt.Println("Usage: task-cli list || task-cli list todo || task-cli list in-progress || task-cli list done")
+				flags.project = args[i]
+			}
+		case strings.HasPrefix(arg, "--file="):
+			flags.file = strings.TrimPrefix(arg, "--file=")
+		case strings.HasPrefix(arg, "--project="):
+			flags.project = strings.TrimPrefix(arg, "--project=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return flags, rest, nil
+}
+
+// takeFlag removes the flag name and its value, given as "name value" or
+// "name=value", from the arguments of a command and returns the value, or
+// "" if the flag is not given, along with the remaining arguments.
+func takeFlag(args []string, name string) (string, []string, error) {
+	var value string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == name:
+			if i+1 >= len(args) {
+				return "", nil, fmt.Errorf("flag %s needs a value", name)
 			}
+			i++
+			value = args[i]
+		case strings.HasPrefix(arg, name+"="):
+			value = strings.TrimPrefix(arg, name+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return value, rest, nil
+}
+
+// takeSwitch removes the flag name, which takes no value, from args and
+// reports whether it was given, along with the remaining arguments.
+func takeSwitch(args []string, name string) (bool, []string) {
+	var found bool
+	var rest []string
+	for _, arg := range args {
+		if arg == name {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return found, rest
+}
+
+// takeTags removes the tags given as "+tag" from args and, with minus,
+// those given as "-tag", and returns them without the sign along with the
+// remaining arguments. Flags such as --due are not tags.
+func takeTags(args []string, minus bool) (plus, minusTags, rest []string) {
+	for _, arg := range args {
+		switch {
+		case len(arg) > 1 && arg[0] == '+':
+			plus = append(plus, arg[1:])
+		case minus && len(arg) > 1 && arg[0] == '-' && arg[1] != '-':
+			minusTags = append(minusTags, arg[1:])
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return plus, minusTags, rest
+}
+
+// parseTagList parses a comma-separated list of tags.
+func parseTagList(s string) ([]string, error) {
+	var tags []string
+	for _, name := range strings.Split(s, ",") {
+		tag, err := tracker.ParseTag(name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// parseTaskFlags removes the flags of add and update that set task fields,
+// --priority, --due and --parent, from args and returns the fields they set
+// along with the remaining arguments. Due dates are parsed relative to now;
+// "none" gives the zero time, which removes the due date, and parent 0,
+// which makes the task a top-level task.
+func parseTaskFlags(args []string, now time.Time) (tracker.TaskUpdate, []string, error) {
+	var update tracker.TaskUpdate
+	value, rest, err := takeFlag(args, "--priority")
+	if err != nil {
+		return update, nil, err
+	}
+	if value != "" {
+		priority, err := tracker.ParsePriority(value)
+		if err != nil {
+			return update, nil, err
+		}
+		update.Priority = &priority
+	}
+
+	value, rest, err = takeFlag(rest, "--due")
+	if err != nil {
+		return update, nil, err
+	}
+	switch value {
+	case "":
+	case "none":
+		update.DueAt = &time.Time{}
+	default:
+		due, err := tracker.ParseDue(value, now)
+		if err != nil {
+			return update, nil, err
 		}
+		update.DueAt = &due
+	}
 
+	value, rest, err = takeFlag(rest, "--parent")
+	if err != nil {
+		return update, nil, err
+	}
+	switch value {
+	case "":
+	case "none":
+		update.ParentID = new(int)
 	default:
-		fmt.Println("Unknown command:", command)
+		parent, err := tracker.ParseID(value)
+		if err != nil {
+			return update, nil, fmt.Errorf("invalid parent %q: %w", value, err)
+		}
+		update.ParentID = &parent
+	}
+	return update, rest, nil
+}
+
+// listOptions are the arguments of the list command.
+type listOptions struct {
+	// status is the status argument, "all" if none is given.
+	status      string
+	query       tracker.Query
+	allProjects bool
+}
+
+// parseListArgs parses the arguments of the list command: an optional
+// status (see statusFilter), tags to have, +a,b meaning a or b, and tags
+// not to have, -a,b meaning neither a nor b, --priority with a
+// comma-separated list of priorities, --overdue, --due-before with a due
+// date parsed relative to now, --sort and --all-projects.
+func parseListArgs(args []string, now time.Time) (listOptions, error) {
+	options := listOptions{status: "all"}
+	options.query.Now = now
+
+	with, without, args := takeTags(args, true)
+	for _, group := range with {
+		tags, err := parseTagList(group)
+		if err != nil {
+			return options, err
+		}
+		options.query.Tags = append(options.query.Tags, tags)
+	}
+	for _, group := range without {
+		tags, err := parseTagList(group)
+		if err != nil {
+			return options, err
+		}
+		options.query.WithoutTags = append(options.query.WithoutTags, tags...)
+	}
+	options.allProjects, args = takeSwitch(args, "--all-projects")
+	options.query.Overdue, args = takeSwitch(args, "--overdue")
+
+	dueBefore, args, err := takeFlag(args, "--due-before")
+	if err != nil {
+		return options, err
+	}
+	if dueBefore != "" {
+		due, err := tracker.ParseDue(dueBefore, now)
+		if err != nil {
+			return options, err
+		}
+		options.query.DueBefore = &due
+	}
+
+	priorities, args, err := takeFlag(args, "--priority")
+	if err != nil {
+		return options, err
 	}
+	if priorities != "" {
+		for _, name := range strings.Split(priorities, ",") {
+			priority, err := tracker.ParsePriority(strings.TrimSpace(name))
+			if err != nil {
+				return options, err
+			}
+			options.query.Priorities = append(options.query.Priorities, priority)
+		}
+	}
+
+	sortKey, args, err := takeFlag(args, "--sort")
+	if err != nil {
+		return options, err
+	}
+	if sortKey != "" {
+		if options.query.Sort, err = tracker.ParseSortKey(sortKey); err != nil {
+			return options, err
+		}
+	}
+
+	if len(args) > 1 {
+		return options, fmt.Errorf("unexpected arguments %q", args[1:])
+	}
+	if len(args) == 1 {
+		options.status = args[0]
+	}
+	if options.query.Statuses, err = statusFilter(options.status); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// printLocation prints the task file in use and why it was chosen.
+func printLocation(location tracker.TaskFileLocation, cwd string) {
+	fmt.Println(location.Path)
+	fmt.Println("Reason:", location.Reason)
+
+	// Older versions always used tasks.json in the working directory
+	legacy := filepath.Join(cwd, "tasks.json")
+	if legacy != location.Path {
+		if _, err := os.Stat(legacy); err == nil {
+			fmt.Printf("Note: %s is not used anymore; rename it to %s to keep using it in this directory\n", legacy, tracker.LocalTaskFile)
+		}
+	}
+}
+
+// stdin is shared by the prompts, so that answers piped in for several
+// prompts are not lost to one prompt's read buffer.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on stdin. Anything but "y" or "yes" is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
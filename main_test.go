@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
+)
+
+// TestMain runs the tests and removes the backup copy that the tests using
+// tasks.json in the working directory leave behind.
+func TestMain(m *testing.M) {
+	code := m.Run()
+	os.Remove("tasks.json.bak")
+	os.Remove("tasks.json.lock")
+	os.Exit(code)
+}
+
+// TestParseGlobalFlags tests that global flags are removed from the arguments.
+//
+// The test includes the following cases:
+//
+//  1. Flag before and after the command: The test checks that --file is picked
+//     up in both spellings and the remaining arguments are kept in order.
+//
+//  2. Missing value: The test checks that an error is returned.
+func TestParseGlobalFlags(t *testing.T) {
+	// Case 1: Flag di depan dan di belakang command
+	flags, args, err := parseGlobalFlags([]string{"--file", "a.json", "add", "Task"})
+	if err != nil || flags.file != "a.json" || len(args) != 2 || args[0] != "add" {
+		t.Errorf("Unexpected result %+v %v (err %v)", flags, args, err)
+	}
+	flags, args, _ = parseGlobalFlags([]string{"list", "--file=b.json"})
+	if flags.file != "b.json" || len(args) != 1 || args[0] != "list" {
+		t.Errorf("Unexpected result %+v %v", flags, args)
+	}
+
+	// Case 2: Flag tanpa nilai
+	if _, _, err := parseGlobalFlags([]string{"list", "--file"}); err == nil {
+		t.Fatal("Expected error for missing value, got none")
+	}
+}
+
+// TestParseListArgs tests parsing the arguments of the list command.
+//
+// The test includes the following cases:
+//
+//  1. Status and flags: The test checks that the status, --priority with two
+//     priorities, --overdue, --due-before, --sort and --all-projects are
+//     picked up in any order.
+//
+//  2. No arguments: The test checks that every status is listed by priority.
+//
+//  3. Invalid arguments: The test checks that an unknown status, priority,
+//     sort key, due date or an invalid tag and a second status are refused.
+//
+//  4. Tags: The test checks that every +tags argument is a group of
+//     alternatives and that -tags arguments are all left out, in lower case.
+func TestParseListArgs(t *testing.T) {
+	// Case 1: Status dan flag
+	now := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC)
+	options, err := parseListArgs([]string{"--priority", "high,critical", "todo", "--overdue", "--all-projects", "--sort=id", "--due-before", "tomorrow"}, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if options.status != "todo" || len(options.query.Statuses) != 1 || !options.allProjects || options.query.Sort != tracker.SortID {
+		t.Errorf("Unexpected options %+v", options)
+	}
+	if due := options.query.DueBefore; !options.query.Overdue || due == nil || !due.Equal(time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected overdue tasks due before 2024-05-16, got %+v", options.query)
+	}
+	if p := options.query.Priorities; len(p) != 2 || p[0] != tracker.PriorityHigh || p[1] != tracker.PriorityCritical {
+		t.Errorf("Expected high and critical, got %v", p)
+	}
+
+	// Case 2: Tanpa argumen
+	options, err = parseListArgs(nil, time.Now())
+	if err != nil || options.status != "all" || options.query.Statuses != nil || options.query.Sort != "" {
+		t.Errorf("Unexpected options %+v (err %v)", options, err)
+	}
+
+	// Case 3: Argumen tidak valid
+	for _, args := range [][]string{{"wip"}, {"--priority", "urgent"}, {"--sort", "size"}, {"--due-before", "someday"}, {"todo", "done"}, {"--priority"}, {"+a,,b"}} {
+		if _, err := parseListArgs(args, time.Now()); err == nil {
+			t.Errorf("Expected an error for %q", args)
+		}
+	}
+
+	// Case 4: Tag
+	options, err = parseListArgs([]string{"+Backend,infra", "todo", "-docs,ops", "+urgent", "-wip"}, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tags := options.query.Tags; len(tags) != 2 || len(tags[0]) != 2 || tags[0][0] != "backend" || tags[1][0] != "urgent" {
+		t.Errorf("Expected the groups backend,infra and urgent, got %q", tags)
+	}
+	if without := options.query.WithoutTags; len(without) != 3 || options.status != "todo" {
+		t.Errorf("Expected docs, ops and wip left out of todo, got %q in %s", without, options.status)
+	}
+}
+
+// TestAddArgs tests how the add and update commands read their arguments.
+//
+// The test includes the following cases:
+//
+//  1. Description starting with "+": The test checks that the task name is
+//     kept as the description and only the +tags after it are tags.
+//
+//  2. Extra arguments: The test checks that arguments after the task name
+//     that are not tags add no task and that an update with more than one
+//     description argument changes nothing.
+func TestAddArgs(t *testing.T) {
+	SetStore(tracker.NewMemoryStore())
+	defer SetStore(tracker.NewJSONFileStore("tasks.json"))
+
+	// Case 1: Deskripsi yang diawali "+"
+	runCommand([]string{"add", "+1 for the new design", "+Design", "--priority", "high"}, commandEnv{})
+	task, err := store.Get(1)
+	if err != nil || task.Description != "+1 for the new design" || len(task.Tags) != 1 || task.Tags[0] != "design" || task.Priority != tracker.PriorityHigh {
+		t.Errorf("Expected the task with the tag design, got %+v (err %v)", task, err)
+	}
+
+	// Case 2: Argumen berlebih
+	runCommand([]string{"add", "buy", "milk"}, commandEnv{})
+	if list, _ := store.Load(); len(list.Tasks) != 1 {
+		t.Errorf("Expected no task added, got %+v", list.Tasks)
+	}
+	runCommand([]string{"update", "1", "buy", "milk"}, commandEnv{})
+	if task, _ := store.Get(1); task.Description != "+1 for the new design" {
+		t.Errorf("Expected the description unchanged, got %q", task.Description)
+	}
+}
@@ -1,205 +1,316 @@
 package main
 
 import (
-	"errors"
+	"bufio"
 	"fmt"
-	"strconv"
+	"os"
+	"strings"
 	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
 )
 
-type Task struct {
-	ID          int       `json:"id"`
-	Description string    `json:"description"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+// store is the TaskStore the commands run against. main replaces it with a
+// store for the resolved task file; see tracker.ResolveTaskFile.
+var store tracker.TaskStore = tracker.NewJSONFileStore("tasks.json")
+
+// SetStore replaces the store used by the task commands.
+func SetStore(s tracker.TaskStore) {
+	store = s
+}
+
+// workflow gives the statuses the commands accept; main replaces it with
+// the one of the config file.
+var workflow = tracker.DefaultWorkflow
+
+// SetWorkflow replaces the workflow used by the task commands.
+func SetWorkflow(w tracker.Workflow) {
+	workflow = w
+}
+
+// subtaskPolicy says what happens to the subtasks of deleted and closed
+// tasks; main replaces it with the one of the config file.
+var subtaskPolicy = tracker.ChildrenBlock
+
+// SetSubtaskPolicy replaces the subtask policy used by the task commands.
+func SetSubtaskPolicy(p tracker.ChildPolicy) {
+	subtaskPolicy = p
 }
 
-// AddTask adds a new task to the tasks.json
+// tasks returns a Tracker for the store the commands run against.
+func tasks() *tracker.Tracker {
+	return &tracker.Tracker{Store: store, Workflow: workflow, Subtasks: subtaskPolicy}
+}
+
+// AddTask adds a new task to the configured store
 func AddTask(description string) error {
-	// Validate task description
-	if err := ValidateDescription(description); err != nil {
-		return err
-	}
+	return addTask(tracker.Task{Description: description})
+}
 
-	// Load existing tasks
-	tasks, err := LoadTasks()
+// addTask adds a task with the description and the other fields of draft
+// that the add command sets, such as the priority.
+func addTask(draft tracker.Task) error {
+	task, err := tasks().AddTask(draft)
 	if err != nil {
 		return err
 	}
 
-	// Create new task
-	newTask := Task{
-		ID:          len(tasks) + 1, // Incremental ID based on task count
-		Description: description,
-		Status:      "todo", // Default status is "todo"
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
+	fmt.Printf("Task added successfully (ID: %d)\n", task.ID)
+	return nil
+}
 
-	// Append new task to the list
-	tasks = append(tasks, newTask)
+// Fungsi untuk mengupdate task berdasarkan ID dan deskripsi baru
+func UpdateTask(id string, newDescription string) error {
+	return updateTask(id, tracker.TaskUpdate{Description: &newDescription})
+}
 
-	// Save tasks back to tasks.json
-	if err := SaveTasks(tasks); err != nil {
+// updateTask changes the fields of the task with the given ID that update
+// sets.
+func updateTask(id string, update tracker.TaskUpdate) error {
+	// Validasi ID task yang diberikan
+	taskID, err := tracker.ParseID(id)
+	if err != nil {
+		return err
+	}
+	if _, err := tasks().UpdateTask(taskID, update); err != nil {
 		return err
 	}
 
-	fmt.Printf("Task added successfully (ID: %d)\n", newTask.ID)
+	fmt.Printf("Task (ID: %d) updated successfully\n", taskID)
 	return nil
 }
 
-// Fungsi untuk mengupdate task berdasarkan ID dan deskripsi baru
-func UpdateTask(id string, newDescription string) error {
+// Fungsi untuk menghapus task berdasarkan ID. Task dipindahkan ke trash
+// dan bisa dikembalikan dengan RestoreTask.
+func DeleteTask(id string) error {
 	// Validasi ID task yang diberikan
-	taskID, err := strconv.Atoi(id)
+	taskID, err := tracker.ParseID(id)
 	if err != nil {
-		return errors.New("invalid task ID")
+		return err
 	}
-
-	// Validasi deskripsi task
-	if err := ValidateDescription(newDescription); err != nil {
+	if _, err := tasks().Delete(taskID); err != nil {
 		return err
 	}
 
-	// Muat task dari file JSON
-	tasks, err := LoadTasks()
+	fmt.Printf("Task (ID: %d) deleted successfully (moved to trash)\n", taskID)
+	return nil
+}
+
+// Fungsi untuk menandai task dengan status dari workflow berdasarkan ID
+func MarkTask(id string, newStatus string) error {
+	// Validasi ID task dan status yang diberikan
+	taskID, err := tracker.ParseID(id)
 	if err != nil {
 		return err
 	}
-
-	// Cari task dengan ID yang cocok
-	taskFound := false
-	for i, task := range tasks {
-		if task.ID == taskID {
-			// Task ditemukan, lakukan update deskripsi dan updatedAt
-			tasks[i].Description = newDescription
-			tasks[i].UpdatedAt = time.Now()
-			taskFound = true
-			break
-		}
+	status, err := workflow.Parse(newStatus)
+	if err != nil {
+		return err
 	}
-
-	if !taskFound {
-		return errors.New("task ID not found")
+	if _, err := tasks().Mark(taskID, status); err != nil {
+		return err
 	}
 
-	// Simpan task yang sudah diupdate ke file JSON
-	if err := SaveTasks(tasks); err != nil {
+	fmt.Printf("Task (ID: %d) marked as %s successfully\n", taskID, newStatus)
+	return nil
+}
+
+// ReopenTask moves the task with the given ID back to the first status of
+// the workflow, todo by default.
+func ReopenTask(id string) error {
+	taskID, err := tracker.ParseID(id)
+	if err != nil {
+		return err
+	}
+	if _, err := tasks().Reopen(taskID); err != nil {
 		return err
 	}
 
-	fmt.Printf("Task (ID: %d) updated successfully\n", taskID)
+	fmt.Printf("Task (ID: %d) reopened successfully\n", taskID)
 	return nil
 }
 
-// Fungsi untuk menghapus task berdasarkan ID
-func DeleteTask(id string) error {
-	// Validasi ID task yang diberikan
-	taskID, err := strconv.Atoi(id)
-	if err != nil || taskID <= 0 {
-		return errors.New("invalid task ID")
+// TagTask adds the tags add to the task with the given ID and removes the
+// tags remove from it.
+func TagTask(id string, add, remove []string) error {
+	taskID, err := tracker.ParseID(id)
+	if err != nil {
+		return err
 	}
-
-	// Muat task dari file JSON
-	tasks, err := LoadTasks()
+	task, err := tasks().Tag(taskID, add, remove)
 	if err != nil {
 		return err
 	}
 
-	// Cari task dengan ID yang cocok
-	taskFound := false
-	newTasks := make([]Task, 0)
-	for _, task := range tasks {
-		if task.ID == taskID {
-			taskFound = true
-			continue // Task dengan ID ini akan di-skip (dihapus)
-		}
-		newTasks = append(newTasks, task)
+	if len(task.Tags) == 0 {
+		fmt.Printf("Task (ID: %d) tagged successfully (no tags left)\n", taskID)
+		return nil
 	}
+	fmt.Printf("Task (ID: %d) tagged successfully (tags: %s)\n", taskID, strings.Join(task.Tags, ", "))
+	return nil
+}
 
-	if !taskFound {
-		return errors.New("task ID not found")
+// ListTags prints every tag with the number of open and done tasks that
+// have it. Tasks count as done when their status is closed.
+func ListTags() error {
+	counts, err := tasks().Tags()
+	if err != nil {
+		return err
+	}
+
+	if len(counts) == 0 {
+		fmt.Println("No tags found.")
+		return nil
 	}
+	for _, c := range counts {
+		fmt.Printf("%s: %d open, %d done\n", c.Tag, c.Open, c.Closed)
+	}
+	return nil
+}
 
-	// Simpan task yang sudah diupdate (tanpa task yang dihapus) ke file JSON
-	if err := SaveTasks(newTasks); err != nil {
+// RenameTag renames a tag on every task.
+func RenameTag(from, to string) error {
+	renamed, err := tasks().RenameTag(from, to)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Task (ID: %d) deleted successfully\n", taskID)
+	fmt.Printf("Tag %s renamed to %s on %d task(s)\n", from, to, renamed)
 	return nil
 }
 
-// Fungsi untuk menandai task sebagai "in-progress" atau "done" berdasarkan ID
-func MarkTask(id string, newStatus string) error {
-	// Validasi ID task yang diberikan
-	taskID, err := strconv.Atoi(id)
-	if err != nil || taskID <= 0 {
-		return errors.New("invalid task ID")
+// Fungsi untuk menampilkan task berdasarkan argumen status yang diberikan.
+func ListTasks(status string) error {
+	statuses, err := statusFilter(status)
+	if err != nil {
+		return err
 	}
+	return listTasks(tracker.Query{Statuses: statuses})
+}
 
-	// Muat task dari file JSON
-	tasks, err := LoadTasks()
+// listTasks prints the tasks query selects, most important first unless
+// it asks for another order, with subtasks indented under their parents.
+func listTasks(query tracker.Query) error {
+	// Muat task yang cocok dari store sebagai pohon
+	list, err := tasks().QueryTree(query)
 	if err != nil {
 		return err
 	}
 
-	// Cari task dengan ID yang cocok
-	taskFound := false
-	for i, task := range tasks {
-		if task.ID == taskID {
-			tasks[i].Status = newStatus     // Update status menjadi in-progress
-			tasks[i].UpdatedAt = time.Now() // Update waktu
-			taskFound = true
-			break
-		}
+	// Tulis lewat buffer; satu write per task lambat untuk list besar
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	// Cek jika tidak ada task
+	if len(list) == 0 {
+		fmt.Fprintln(out, "No tasks found.")
+		return nil
+	}
+
+	// Looping dan print setiap task
+	now := tasks().Now()
+	for _, node := range list {
+		fmt.Fprintln(out, formatNode(node, now))
 	}
+	return nil
+}
+
+// statusFilter returns the statuses to list for the status argument of the
+// list command; see tracker.Workflow.Filter.
+func statusFilter(status string) ([]tracker.Status, error) {
+	return workflow.Filter(status)
+}
+
+// formatTask renders a task the way ListTasks prints it, flagging it if it
+// is overdue at now.
+func formatTask(task tracker.Task, now time.Time) string {
+	return formatNode(tracker.TreeNode{Task: task}, now)
+}
 
-	if !taskFound {
-		return errors.New("task ID not found")
+// formatNode renders a task of a task tree, indented by its depth and with
+// the progress of its subtasks. Top-level subtasks, whose parent is not
+// shown, name it.
+func formatNode(node tracker.TreeNode, now time.Time) string {
+	task := node.Task
+	parent := ""
+	if node.Depth == 0 && task.ParentID != 0 {
+		parent = fmt.Sprintf(", Parent: %d", task.ParentID)
 	}
+	progress := ""
+	if node.Progress.Total > 0 {
+		progress = fmt.Sprintf(", Progress: %d/%d done", node.Progress.Done, node.Progress.Total)
+	}
+	due := ""
+	if task.DueAt != nil {
+		due = ", Due: " + task.DueAt.Format("2006-01-02")
+		if task.Overdue(now, workflow) {
+			due += " (overdue)"
+		}
+	}
+	tags := ""
+	if len(task.Tags) > 0 {
+		tags = ", Tags: " + strings.Join(task.Tags, ", ")
+	}
+	return fmt.Sprintf("%sID: %d, Description: %s, Status: %s%s, Priority: %s%s%s%s, CreatedAt: %s, UpdatedAt: %s",
+		strings.Repeat("  ", node.Depth), task.ID, task.Description, workflow.Label(task.Status), progress, task.Priority, due, tags, parent,
+		task.CreatedAt.Format("2006-01-02 15:04:05"), task.UpdatedAt.Format("2006-01-02 15:04:05"))
+}
+
+// LoadTasks returns the tasks of the configured store that are not in the
+// trash.
+func LoadTasks() ([]tracker.Task, error) {
+	return tasks().Tasks()
+}
 
-	// Simpan task yang sudah diupdate ke file JSON
-	if err := SaveTasks(tasks); err != nil {
+// SaveTasks replaces the tasks of the configured store that are not in the
+// trash, keeping the ID counter and the trash.
+func SaveTasks(list []tracker.Task) error {
+	return tasks().ReplaceTasks(list)
+}
+
+// ListTrash prints the tasks in the trash.
+func ListTrash() error {
+	trash, err := tasks().Trash()
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Task (ID: %d) marked as %s successfully\n", taskID, newStatus)
+	if len(trash) == 0 {
+		fmt.Println("Trash is empty.")
+		return nil
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, task := range trash {
+		fmt.Fprintf(out, "ID: %d, Description: %s, Status: %s, DeletedAt: %s\n",
+			task.ID, task.Description, workflow.Label(task.Status), task.DeletedAt.Format("2006-01-02 15:04:05"))
+	}
 	return nil
 }
 
-// Fungsi untuk menampilkan task berdasarkan argumen status yang diberikan.
-func ListTasks(status string) error {
-	// Muat semua task dari file JSON
-	tasks, err := LoadTasks()
+// RestoreTask brings the task with the given ID back from the trash. It
+// keeps its original ID.
+func RestoreTask(id string) error {
+	taskID, err := tracker.ParseID(id)
 	if err != nil {
 		return err
 	}
-
-	var processedTask []Task
-	// Looping dan print setiap task
-	if status == "all" {
-		for _, task := range tasks {
-			processedTask = append(processedTask, task)
-			fmt.Printf("ID: %d, Description: %s, Status: %s, CreatedAt: %s, UpdatedAt: %s\n",
-				task.ID, task.Description, task.Status, task.CreatedAt.Format("2006-01-02 15:04:05"), task.UpdatedAt.Format("2006-01-02 15:04:05"))
-		}
-	} else {
-		for _, task := range tasks {
-			if task.Status == status {
-				processedTask = append(processedTask, task)
-				fmt.Printf("ID: %d, Description: %s, Status: %s, CreatedAt: %s, UpdatedAt: %s\n",
-					task.ID, task.Description, task.Status, task.CreatedAt.Format("2006-01-02 15:04:05"), task.UpdatedAt.Format("2006-01-02 15:04:05"))
-			}
-		}
+	if _, err := tasks().Restore(taskID); err != nil {
+		return err
 	}
 
-	// Cek jika tidak ada task
-	if len(processedTask) == 0 {
-		fmt.Println("No tasks found.")
-		return nil
+	fmt.Printf("Task (ID: %d) restored successfully\n", taskID)
+	return nil
+}
+
+// PurgeTrash removes tasks from the trash for good. With olderThan above
+// zero only tasks deleted at least that long ago are removed.
+func PurgeTrash(olderThan time.Duration) error {
+	purged, err := tasks().PurgeTrash(olderThan)
+	if err != nil {
+		return err
 	}
 
+	fmt.Printf("Purged %d task(s) from the trash\n", purged)
 	return nil
 }
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/relieyanhilman/task-tracker-cli/tracker"
 )
 
 // TestAddTask tests the AddTask function for adding new tasks.
@@ -70,19 +72,19 @@ func TestAddTask(t *testing.T) {
 //     test checks that an error is returned.
 func TestValidateDescription(t *testing.T) {
 	// Case 1: Deskripsi valid
-	err := ValidateDescription("Learn Go")
+	err := tracker.ValidateDescription("Learn Go")
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
 
 	// Case 2: Deskripsi kosong
-	err = ValidateDescription("")
+	err = tracker.ValidateDescription("")
 	if err == nil {
 		t.Fatal("Expected error for empty description, got none")
 	}
 
 	// Case 3: Deskripsi dengan hanya spasi
-	err = ValidateDescription("   ")
+	err = tracker.ValidateDescription("   ")
 	if err == nil {
 		t.Fatal("Expected error for spaces-only description, got none")
 	}
@@ -110,14 +112,14 @@ func TestValidateDescription(t *testing.T) {
 func TestUpdateTask(t *testing.T) {
 	// Setup: Buat file tasks.json dengan task dummy untuk testing
 	os.Remove("tasks.json")
-	task := Task{
+	task := tracker.Task{
 		ID:          1,
 		Description: "Original description",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task})
+	SaveTasks([]tracker.Task{task})
 
 	// Case 1: Update task dengan ID valid dan deskripsi baru
 	err := UpdateTask("1", "Updated description")
@@ -188,21 +190,21 @@ func TestUpdateTask(t *testing.T) {
 func TestDeleteTask(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2})
+	SaveTasks([]tracker.Task{task1, task2})
 
 	// Case 1: Menghapus task dengan ID valid
 	err := DeleteTask("1")
@@ -269,21 +271,21 @@ func TestDeleteTask(t *testing.T) {
 func TestMarkTaskInProgress(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2})
+	SaveTasks([]tracker.Task{task1, task2})
 
 	// Case 1: Menandai task dengan ID valid
 	err := MarkTask("1", "in-progress")
@@ -351,21 +353,21 @@ func TestMarkTaskInProgress(t *testing.T) {
 func TestMarkTaskDone(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2})
+	SaveTasks([]tracker.Task{task1, task2})
 
 	// Case 1: Menandai task dengan ID valid
 	err := MarkTask("1", "done")
@@ -423,21 +425,21 @@ func TestMarkTaskDone(t *testing.T) {
 func TestListAllTasks(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "in-progress",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2})
+	SaveTasks([]tracker.Task{task1, task2})
 
 	// Capture output untuk testing
 	old := os.Stdout
@@ -478,28 +480,28 @@ func TestListAllTasks(t *testing.T) {
 func TestListTodoTasks(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "in-progress",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task3 := Task{
+	task3 := tracker.Task{
 		ID:          3,
 		Description: "Task 3",
 		Status:      "done",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2, task3})
+	SaveTasks([]tracker.Task{task1, task2, task3})
 
 	// Capture output untuk testing
 	old := os.Stdout
@@ -537,28 +539,28 @@ func TestListTodoTasks(t *testing.T) {
 func TestListInProgressTasks(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "in-progress",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task3 := Task{
+	task3 := tracker.Task{
 		ID:          3,
 		Description: "Task 3",
 		Status:      "done",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2, task3})
+	SaveTasks([]tracker.Task{task1, task2, task3})
 
 	// Capture output untuk testing
 	old := os.Stdout
@@ -595,28 +597,28 @@ func TestListInProgressTasks(t *testing.T) {
 func TestListDoneTasks(t *testing.T) {
 	// Setup: Buat file tasks.json dengan beberapa task dummy untuk testing
 	os.Remove("tasks.json")
-	task1 := Task{
+	task1 := tracker.Task{
 		ID:          1,
 		Description: "Task 1",
 		Status:      "todo",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task2 := Task{
+	task2 := tracker.Task{
 		ID:          2,
 		Description: "Task 2",
 		Status:      "in-progress",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	task3 := Task{
+	task3 := tracker.Task{
 		ID:          3,
 		Description: "Task 3",
 		Status:      "done",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	SaveTasks([]Task{task1, task2, task3})
+	SaveTasks([]tracker.Task{task1, task2, task3})
 
 	// Capture output untuk testing
 	old := os.Stdout
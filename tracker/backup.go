@@ -0,0 +1,212 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp in backup file names. It sorts in time
+// order.
+const backupTimeFormat = "20060102-150405.000"
+
+// BackupPolicy configures the rolling backups written before every save.
+// Backups go to a "backups" directory next to the task file.
+type BackupPolicy struct {
+	// Keep is the number of most recent backups to keep.
+	Keep int `json:"keep"`
+	// Daily is the number of days for which the newest backup of the day
+	// is kept on top of the Keep most recent ones.
+	Daily int `json:"daily"`
+}
+
+// Enabled reports whether backups should be written at all.
+func (p BackupPolicy) Enabled() bool {
+	return p.Keep > 0 || p.Daily > 0
+}
+
+// Backup describes one backup file.
+type Backup struct {
+	Name string
+	Path string
+	At   time.Time
+}
+
+// backupDir returns the directory holding the backups of the task file at
+// path.
+func backupDir(path string) string {
+	return filepath.Join(filepath.Dir(path), "backups")
+}
+
+// writeRotatingBackup copies the task file at path into the backup
+// directory and prunes old backups according to policy. A missing task
+// file is not an error; there is nothing to back up yet.
+func writeRotatingBackup(path string, policy BackupPolicy, now time.Time) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	dir := backupDir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := backupName(path, now)
+	if err := writeFileAtomic(filepath.Join(dir, name), data, 0644); err != nil {
+		return err
+	}
+	return pruneBackups(path, policy, now)
+}
+
+// backupName returns the file name of a backup of path taken at t, e.g.
+// "tasks-20240131-093000.000.json".
+func backupName(path string, t time.Time) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	return base + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// ListBackups returns the backups of the task file at path, newest first.
+func ListBackups(path string) ([]Backup, error) {
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+	entries, err := os.ReadDir(backupDir(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue // Another task file's backup or something else
+		}
+		backups = append(backups, Backup{Name: name, Path: filepath.Join(backupDir(path), name), At: at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].At.After(backups[j].At) })
+	return backups, nil
+}
+
+// pruneBackups removes the backups policy does not keep: everything but
+// the Keep most recent ones and the newest one of each of the last Daily
+// days.
+func pruneBackups(path string, policy BackupPolicy, now time.Time) error {
+	backups, err := ListBackups(path)
+	if err != nil {
+		return err
+	}
+
+	keepDays := make(map[string]bool)
+	oldestDay := now.AddDate(0, 0, -policy.Daily)
+	for i, b := range backups {
+		day := b.At.Local().Format("2006-01-02")
+		switch {
+		case i < policy.Keep:
+		case policy.Daily > 0 && b.At.After(oldestDay) && !keepDays[day]:
+		default:
+			if err := os.Remove(b.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		// Backups are newest first, so this one is the newest of its day
+		keepDays[day] = true
+	}
+	return nil
+}
+
+// RestoreFromBackup replaces the tasks with the contents of the named
+// backup of the task file at path, if confirm, which is given the current
+// and the backed up tasks, returns true. It reports whether the backup was
+// restored. The current tasks are backed up by the save as usual, and the
+// restore can be undone.
+func (t *Tracker) RestoreFromBackup(path, name string, confirm func(current, backup *TaskList) bool) (bool, error) {
+	if name != filepath.Base(name) {
+		return false, errors.New("invalid backup name")
+	}
+	backup, err := t.ReadTaskFile(filepath.Join(backupDir(path), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, fmt.Errorf("backup %s not found", name)
+		}
+		return false, err
+	}
+	current, err := t.Store.Load()
+	if err != nil {
+		return false, err
+	}
+	if !confirm(current, backup) {
+		return false, nil
+	}
+
+	// IDs handed out since the backup was taken stay used
+	if backup.NextID < current.NextID {
+		backup.NextID = current.NextID
+	}
+	if err := t.Store.Save(backup); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SummarizeChanges counts changes by kind, e.g. "2 added, 1 updated".
+func SummarizeChanges(changes []TaskChange) string {
+	counts := make(map[string]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	var parts []string
+	for _, k := range []struct{ kind, label string }{
+		{ChangeAdd, "added"},
+		{ChangeUpdate, "updated"},
+		{ChangeMark, "status changed"},
+		{ChangeDelete, "moved to trash"},
+		{ChangeRestore, "restored from trash"},
+		{ChangePurge, "removed"},
+	} {
+		if counts[k.kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[k.kind], k.label))
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ReadTaskFile reads and decodes a task file of any supported version,
+// such as a backup. Encrypted files are opened with the Encryption of the
+// store's task file.
+func (t *Tracker) ReadTaskFile(path string) (*TaskList, error) {
+	return readTaskListFile(t.Store, path)
+}
+
+// readTaskListFile reads and decodes a task file of any supported version.
+// Encrypted files are opened with the Encryption of the task file of s,
+// which may be nil.
+func readTaskListFile(s TaskStore, path string) (*TaskList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := TaskFileStore(s)
+	if file == nil {
+		file = &JSONFileStore{}
+	}
+	return file.decode(data)
+}
@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRotatingBackups tests the pruning of rolling backups.
+//
+// The test includes the following cases:
+//
+//  1. Keep and daily: Write backups over ten days, two per day, with Keep 2 and
+//     Daily 3. The test checks that the two newest backups are kept plus the
+//     newest backup of each of the last three days.
+func TestRotatingBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"version": 2, "meta": {"nextId": 1}, "tasks": []}`), 0644)
+	policy := BackupPolicy{Keep: 2, Daily: 3}
+	now := time.Date(2024, 3, 10, 18, 0, 0, 0, time.Local)
+
+	// Case 1: Simpan backup selama sepuluh hari, dua kali sehari
+	for day := 9; day >= 0; day-- {
+		for _, hour := range []int{-8, -2} {
+			at := now.AddDate(0, 0, -day).Add(time.Duration(hour) * time.Hour)
+			if err := writeRotatingBackup(path, policy, at); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+		}
+	}
+
+	backups, err := ListBackups(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var got []string
+	for _, b := range backups {
+		got = append(got, b.At.Local().Format("01-02 15h"))
+	}
+	want := []string{"03-10 16h", "03-10 10h", "03-09 16h", "03-08 16h"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected backups %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected backups %v, got %v", want, got)
+		}
+	}
+}
+
+// TestRestoreFromBackup tests restoring the tasks from a rolling backup.
+//
+// The test includes the following cases:
+//
+//  1. Declined: Decline the confirmation. The test checks that nothing changes.
+//
+//  2. Confirmed: Confirm the restore. The test checks that the backed up tasks
+//     are back and that IDs handed out since the backup are not reused.
+func TestRestoreFromBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	fileStore := NewJSONFileStore(path)
+	fileStore.Backups = BackupPolicy{Keep: 10}
+	tr := New(fileStore)
+
+	tr.Add("First")
+	tr.Add("Second") // Backs up the list holding only "First"
+	backups, _ := ListBackups(path)
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d", len(backups))
+	}
+
+	// Case 1: Restore dibatalkan
+	decline := func(current, backup *TaskList) bool { return false }
+	if restored, err := tr.RestoreFromBackup(path, backups[0].Name, decline); err != nil || restored {
+		t.Fatalf("Expected nothing restored, got %v (err %v)", restored, err)
+	}
+	if tasks, _ := tr.Tasks(); len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks after declined restore, got %d", len(tasks))
+	}
+
+	// Case 2: Restore dikonfirmasi
+	confirm := func(current, backup *TaskList) bool { return len(current.Tasks) == 2 && len(backup.Tasks) == 1 }
+	if restored, err := tr.RestoreFromBackup(path, backups[0].Name, confirm); err != nil || !restored {
+		t.Fatalf("Expected the backup restored, got %v (err %v)", restored, err)
+	}
+	if tasks, _ := tr.Tasks(); len(tasks) != 1 || tasks[0].Description != "First" {
+		t.Errorf("Expected only 'First' after restore, got %+v", tasks)
+	}
+	tr.Add("Third")
+	if task, err := tr.Store.Get(3); err != nil || task.Description != "Third" {
+		t.Errorf("Expected new task to get ID 3, got %+v (err %v)", task, err)
+	}
+}
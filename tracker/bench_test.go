@@ -0,0 +1,104 @@
+package tracker
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// benchSizes are the task list sizes the benchmarks run at.
+var benchSizes = []int{1000, 10000, 100000}
+
+// benchStore writes a task file with n tasks. It returns a function that
+// returns a Tracker with a fresh store for the file, to be called before
+// every command, as each run of task-cli starts without the cache of the
+// last run.
+func benchStore(b *testing.B, n int) func() *Tracker {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "tasks.json")
+	now := time.Now()
+	list := &TaskList{NextID: n + 1, Tasks: make([]Task, n)}
+	for i := range list.Tasks {
+		list.Tasks[i] = Task{ID: i + 1, Description: "Task number " + strconv.Itoa(i), Status: "todo", CreatedAt: now, UpdatedAt: now, UID: newUID()}
+	}
+	if err := NewJSONFileStore(path).Save(list); err != nil {
+		b.Fatal(err)
+	}
+	return func() *Tracker {
+		return New(NewJSONFileStore(path))
+	}
+}
+
+// BenchmarkAdd measures adding a task to lists of growing size.
+func BenchmarkAdd(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			run := benchStore(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := run().Add("Benchmark task"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMark measures marking a task in the middle of lists of growing
+// size.
+func BenchmarkMark(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			run := benchStore(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Alternate so that every run changes the task
+				var err error
+				if i%2 == 0 {
+					_, err = run().Mark(n/2, StatusDone)
+				} else {
+					_, err = run().Reopen(n / 2)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkList measures listing lists of growing size.
+func BenchmarkList(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			run := benchStore(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if tasks, err := run().List(); err != nil || len(tasks) != n {
+					b.Fatalf("Expected %d tasks, got %d (err %v)", n, len(tasks), err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSubtasks measures finding all subtasks of the root of a binary
+// task tree of growing size, as cascading delete and close do.
+func BenchmarkSubtasks(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprintf("tasks=%d", n), func(b *testing.B) {
+			list := &TaskList{NextID: n + 1, Tasks: make([]Task, n)}
+			for i := range list.Tasks {
+				list.Tasks[i] = Task{ID: i + 1, Status: StatusTodo, ParentID: (i + 1) / 2}
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if found := subtasks(list, 1, true); len(found) != n-1 {
+					b.Fatalf("Expected %d subtasks, got %d", n-1, len(found))
+				}
+			}
+		})
+	}
+}
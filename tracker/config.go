@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Storage modes for Config.Storage.
+const (
+	StorageJSON    = "json"
+	StorageJournal = "journal"
+	StorageDir     = "dir"
+)
+
+// Config holds the settings read from the config file.
+type Config struct {
+	// Storage selects how tasks are stored: "json" (the default) rewrites
+	// the task file on every change, "journal" appends each change to a
+	// journal next to it; see JournalStore. "dir" keeps one file per task
+	// in a directory next to the task file; see DirStore.
+	Storage string `json:"storage"`
+	// HistoryLimit is the number of changes undo can walk back. Zero means
+	// DefaultHistoryLimit.
+	HistoryLimit int `json:"historyLimit"`
+	// Backups configures rolling backups of the task file; see
+	// BackupPolicy. They are off unless configured.
+	Backups BackupPolicy `json:"backups"`
+	// Statuses declares the statuses tasks can have; see Workflow.
+	// LoadConfig sets DefaultWorkflow if the config file declares none.
+	Statuses Workflow `json:"statuses"`
+	// Subtasks says what happens to the subtasks of deleted and closed
+	// tasks: "block" (the default), "cascade" or "orphan"; see
+	// ChildPolicy.
+	Subtasks ChildPolicy `json:"subtasks"`
+	// LockTimeout is how long to wait for other task-cli processes. It is
+	// set from the TASK_CLI_LOCK_TIMEOUT environment variable; zero means
+	// DefaultLockTimeout.
+	LockTimeout time.Duration `json:"-"`
+}
+
+// ConfigPath returns the location of the config file: TASK_CLI_CONFIG if
+// set, otherwise $XDG_CONFIG_HOME/task-cli/config.json, where
+// XDG_CONFIG_HOME defaults to ~/.config.
+func ConfigPath() (string, error) {
+	if env := os.Getenv("TASK_CLI_CONFIG"); env != "" {
+		return env, nil
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("cannot determine the config file location: set TASK_CLI_CONFIG or XDG_CONFIG_HOME")
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "task-cli", "config.json"), nil
+}
+
+// LoadConfig reads the config file. A missing file gives the defaults. The
+// TASK_CLI_STORAGE environment variable overrides the storage setting and
+// TASK_CLI_LOCK_TIMEOUT (e.g. "30s") sets the lock timeout. Backups cannot
+// be combined with storage "dir".
+func LoadConfig() (Config, error) {
+	cfg := Config{Storage: StorageJSON}
+
+	path, err := ConfigPath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("reading config %s: %w", path, err)
+		}
+	}
+	if len(cfg.Statuses) == 0 {
+		cfg.Statuses = DefaultWorkflow
+	} else if err := cfg.Statuses.Validate(); err != nil {
+		return cfg, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	if cfg.Subtasks == "" {
+		cfg.Subtasks = ChildrenBlock
+	} else if _, err := ParseChildPolicy(string(cfg.Subtasks)); err != nil {
+		return cfg, fmt.Errorf("reading config %s: %w", path, err)
+	}
+
+	if env := os.Getenv("TASK_CLI_STORAGE"); env != "" {
+		cfg.Storage = env
+	}
+	if env := os.Getenv("TASK_CLI_LOCK_TIMEOUT"); env != "" {
+		timeout, err := time.ParseDuration(env)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid TASK_CLI_LOCK_TIMEOUT: %w", err)
+		}
+		cfg.LockTimeout = timeout
+	}
+	// Backups copy the task file, which directory storage does not write
+	if cfg.Storage == StorageDir && cfg.Backups.Enabled() {
+		return cfg, fmt.Errorf("reading config %s: backups are not supported with storage %q; remove the backups setting or use %q or %q storage", path, StorageDir, StorageJSON, StorageJournal)
+	}
+	return cfg, nil
+}
+
+// OpenStore returns the store for the task file at path as configured by
+// cfg, with undo history.
+func OpenStore(path string, cfg Config) (TaskStore, error) {
+	fileStore := NewJSONFileStore(path)
+	fileStore.Backups = cfg.Backups
+	fileStore.LockTimeout = cfg.LockTimeout
+	fileStore.Encryption = &Encryption{Passphrase: PassphrasePrompt(false)}
+
+	taskStore, err := NewStore(fileStore, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewHistoryStore(taskStore, path+".history", cfg.HistoryLimit), nil
+}
+
+// TaskFileStore returns the JSON file store underneath s, or nil if s is
+// not backed by a task file.
+func TaskFileStore(s TaskStore) *JSONFileStore {
+	switch base := BaseStore(s).(type) {
+	case *JSONFileStore:
+		return base
+	case *JournalStore:
+		return base.Snapshot
+	default:
+		return nil
+	}
+}
+
+// NewStore returns the store for the task file using the storage mode from
+// cfg.
+func NewStore(file *JSONFileStore, cfg Config) (TaskStore, error) {
+	switch cfg.Storage {
+	case "", StorageJSON:
+		return file, nil
+	case StorageJournal:
+		return NewJournalStore(file), nil
+	case StorageDir:
+		dir := NewDirStore(file.Path)
+		dir.LockTimeout = file.LockTimeout
+		return dir, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q (want %q, %q or %q)", cfg.Storage, StorageJSON, StorageJournal, StorageDir)
+	}
+}
@@ -0,0 +1,88 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// The kinds of TaskChange.
+const (
+	ChangeAdd     = "add"
+	ChangeUpdate  = "update"
+	ChangeMark    = "mark"
+	ChangeDelete  = "delete"  // Moved to the trash
+	ChangeRestore = "restore" // Restored from the trash
+	ChangePurge   = "purge"   // Removed for good
+)
+
+// TaskChange describes how one task differs between two task lists. Before
+// is nil for added tasks and After is nil for purged tasks.
+type TaskChange struct {
+	Kind   string
+	Before *Task
+	After  *Task
+}
+
+// ID returns the ID of the changed task.
+func (c TaskChange) ID() int {
+	if c.After != nil {
+		return c.After.ID
+	}
+	return c.Before.ID
+}
+
+// DiffTaskLists returns the changes that turn before into after: purged
+// tasks first, then added and changed tasks in the order of after. A change
+// that only touches the status (and UpdatedAt) is a ChangeMark; moving a
+// task in or out of the trash is a ChangeDelete or ChangeRestore.
+func DiffTaskLists(before, after *TaskList) []TaskChange {
+	var changes []TaskChange
+
+	afterIDs := make(map[int]bool, len(after.Tasks))
+	for _, task := range after.Tasks {
+		afterIDs[task.ID] = true
+	}
+	beforeByID := make(map[int]*Task, len(before.Tasks))
+	for i := range before.Tasks {
+		task := &before.Tasks[i]
+		beforeByID[task.ID] = task
+		if !afterIDs[task.ID] {
+			changes = append(changes, TaskChange{Kind: ChangePurge, Before: task})
+		}
+	}
+
+	for i := range after.Tasks {
+		task := &after.Tasks[i]
+		old, ok := beforeByID[task.ID]
+		switch {
+		case !ok:
+			changes = append(changes, TaskChange{Kind: ChangeAdd, After: task})
+		case !old.Trashed() && task.Trashed():
+			changes = append(changes, TaskChange{Kind: ChangeDelete, Before: old, After: task})
+		case old.Trashed() && !task.Trashed():
+			changes = append(changes, TaskChange{Kind: ChangeRestore, Before: old, After: task})
+		case !sameTask(*old, *task):
+			kind := ChangeUpdate
+			marked := *old
+			marked.Status, marked.UpdatedAt = task.Status, task.UpdatedAt
+			if sameTask(marked, *task) {
+				kind = ChangeMark
+			}
+			changes = append(changes, TaskChange{Kind: kind, Before: old, After: task})
+		}
+	}
+	return changes
+}
+
+// sameTask reports whether a and b would be stored identically. Tasks read
+// back from JSON lose their monotonic clock readings, so this compares the
+// JSON form when a plain comparison fails.
+func sameTask(a, b Task) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,365 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dirMetaFile is the name of the metadata file in a DirStore directory.
+const dirMetaFile = "meta.json"
+
+// dirMeta is the on-disk form of a DirStore's metadata file. It carries the
+// same version and meta as a task file, without the tasks.
+type dirMeta struct {
+	Version int      `json:"version"`
+	Meta    taskMeta `json:"meta"`
+}
+
+// DirStore keeps every task in its own JSON file, named by ID, in a
+// directory next to the task file, plus a metadata file with the ID
+// counter. Changing a task rewrites only that task's file, so task lists
+// kept in git merge cleanly unless the same task was changed on both sides.
+//
+// Trashed tasks keep their file, with their deletion time. DirStore shares
+// its lock with the JSONFileStore for the same task file.
+type DirStore struct {
+	// Path is the task file the directory belongs to; see dirStorePath.
+	Path string
+	Dir  string
+	// LockTimeout is how long to wait for the lock held by another
+	// process. Zero means DefaultLockTimeout.
+	LockTimeout time.Duration
+}
+
+// NewDirStore returns a directory store for the task file at path.
+func NewDirStore(path string) *DirStore {
+	return &DirStore{Path: path, Dir: dirStorePath(path)}
+}
+
+// dirStorePath returns the directory a DirStore for the task file at path
+// uses: the path without its extension, e.g. "tasks" for "tasks.json".
+func dirStorePath(path string) string {
+	ext := filepath.Ext(path)
+	if ext == "" || ext == filepath.Base(path) {
+		return path + ".d"
+	}
+	return strings.TrimSuffix(path, ext)
+}
+
+// taskPath returns the file of the task with the given ID.
+func (s *DirStore) taskPath(id int) string {
+	return filepath.Join(s.Dir, strconv.Itoa(id)+".json")
+}
+
+// lock takes the lock of the task file.
+func (s *DirStore) lock() (func(), error) {
+	return (&JSONFileStore{Path: s.Path, LockTimeout: s.LockTimeout}).lock()
+}
+
+// Exists reports whether the directory holds any tasks or metadata.
+func (s *DirStore) Exists() bool {
+	_, err := os.Stat(filepath.Join(s.Dir, dirMetaFile))
+	return err == nil
+}
+
+// readMeta reads the metadata file. A missing file is the metadata of an
+// empty directory in the current version.
+func (s *DirStore) readMeta() (dirMeta, error) {
+	meta := dirMeta{Version: formatVersion, Meta: taskMeta{NextID: 1}}
+	data, err := os.ReadFile(filepath.Join(s.Dir, dirMetaFile))
+	if os.IsNotExist(err) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, &CorruptFileError{Path: filepath.Join(s.Dir, dirMetaFile), Err: err}
+	}
+	if meta.Version > formatVersion {
+		return meta, fmt.Errorf("%s: %w", s.Dir, &FileVersionError{Version: meta.Version})
+	}
+	return meta, nil
+}
+
+// Load reads every task file in the directory. A missing directory is an
+// empty list. Tasks are returned in ID order.
+func (s *DirStore) Load() (*TaskList, error) {
+	list, _, err := s.load()
+	return list, err
+}
+
+// load is Load, but also returns the format version of the directory.
+// Directories written in an older version are upgraded through the same
+// migrations as task files; see migrateTaskFile.
+func (s *DirStore) load() (*TaskList, int, error) {
+	meta, err := s.readMeta()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, 0, err
+	}
+	list := &TaskList{NextID: meta.Meta.NextID, Tombstones: meta.Meta.Tombstones, Tasks: []Task{}}
+	var raw []json.RawMessage
+	for _, entry := range entries {
+		name := entry.Name()
+		id, err := strconv.Atoi(strings.TrimSuffix(name, ".json"))
+		if err != nil || !strings.HasSuffix(name, ".json") || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.Dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		var header struct{ ID int }
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, 0, &CorruptFileError{Path: path, Err: err}
+		}
+		if header.ID != id {
+			return nil, 0, &CorruptFileError{Path: path, Err: fmt.Errorf("file holds task %d", header.ID)}
+		}
+		if meta.Version < formatVersion {
+			raw = append(raw, data)
+			continue
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			return nil, 0, &CorruptFileError{Path: path, Err: err}
+		}
+		list.Tasks = append(list.Tasks, task)
+	}
+
+	// Older tasks are put together as a task file of their version, so
+	// that they go through the same migrations
+	if meta.Version < formatVersion {
+		if raw == nil {
+			raw = []json.RawMessage{}
+		}
+		data, err := json.Marshal(map[string]any{"version": meta.Version, "meta": meta.Meta, "tasks": raw})
+		if err != nil {
+			return nil, 0, err
+		}
+		if list, _, err = decodeTaskList(data); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", s.Dir, err)
+		}
+	}
+	sort.Slice(list.Tasks, func(i, j int) bool { return list.Tasks[i].ID < list.Tasks[j].ID })
+	list.fixNextID()
+	return list, meta.Version, nil
+}
+
+// Save replaces the tasks in the directory.
+func (s *DirStore) Save(list *TaskList) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, version, err := s.load()
+	if err != nil {
+		return err
+	}
+	return s.save(current, list, version)
+}
+
+// save writes the files of the tasks that differ between current, the
+// state on disk, and list, and removes those of tasks no longer in list.
+// A directory in format version from, if older than the current one, has
+// every task file and the metadata rewritten. The caller must hold the lock.
+func (s *DirStore) save(current, list *TaskList, from int) error {
+	upgrade := from < formatVersion
+	list.fixNextID()
+	if list.hasDuplicateIDs() {
+		return errors.New("cannot save duplicate task IDs")
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	changed := DiffTaskLists(current, list)
+	if upgrade {
+		// Every task is rewritten; keep only the removals
+		removed := changed[:0]
+		for _, c := range changed {
+			if c.After == nil {
+				removed = append(removed, c)
+			}
+		}
+		changed = removed
+		for _, task := range list.Tasks {
+			changed = append(changed, TaskChange{After: &task})
+		}
+	}
+	for _, c := range changed {
+		if c.After == nil {
+			if err := os.Remove(s.taskPath(c.ID())); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		data, err := json.MarshalIndent(c.After, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(s.taskPath(c.ID()), append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	if !upgrade && current.NextID == list.NextID && reflect.DeepEqual(current.Tombstones, list.Tombstones) && s.Exists() {
+		return nil
+	}
+	meta := dirMeta{Version: formatVersion, Meta: taskMeta{NextID: list.NextID, Tombstones: list.Tombstones}}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.Dir, dirMetaFile), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if upgrade {
+		fmt.Fprintf(Notices, "Upgraded %s from format version %d to %d\n", s.Dir, from, formatVersion)
+	}
+	return nil
+}
+
+// Get returns the task with the given ID, reading only its file and the
+// metadata file.
+func (s *DirStore) Get(id int) (Task, error) {
+	meta, err := s.readMeta()
+	if err != nil {
+		return Task{}, err
+	}
+	// Tasks of an older version need the migrations Load runs
+	if meta.Version < formatVersion {
+		list, err := s.Load()
+		if err != nil {
+			return Task{}, err
+		}
+		return getTask(list, id)
+	}
+	data, err := os.ReadFile(s.taskPath(id))
+	if os.IsNotExist(err) {
+		return Task{}, ErrTaskNotFound
+	}
+	if err != nil {
+		return Task{}, err
+	}
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return Task{}, &CorruptFileError{Path: s.taskPath(id), Err: err}
+	}
+	return task, nil
+}
+
+// Modify runs fn on the task list and writes back the tasks it changed.
+// The whole read-modify-write cycle runs under the lock.
+func (s *DirStore) Modify(fn func(list *TaskList) error) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, version, err := s.load()
+	if err != nil {
+		return err
+	}
+	list := current.Clone()
+	if err := fn(list); err != nil {
+		return err
+	}
+	return s.save(current, list, version)
+}
+
+// remove deletes the task files and metadata, and the directory if nothing
+// else is left in it. The caller must hold the lock.
+func (s *DirStore) remove(list *TaskList) error {
+	for _, task := range list.Tasks {
+		if err := os.Remove(s.taskPath(task.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(s.Dir, dirMetaFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	os.Remove(s.Dir) // Fails, harmlessly, if other files are left
+	return nil
+}
+
+// MigrateStorage converts the task file at path to the given storage
+// layout: StorageDir moves the tasks from the task file into a DirStore
+// directory, StorageJSON moves them back. The source is removed once the
+// target has been written. It returns the number of tasks moved.
+func MigrateStorage(path, to string, lockTimeout time.Duration) (int, error) {
+	file := &JSONFileStore{Path: path, LockTimeout: lockTimeout}
+	dir := NewDirStore(path)
+	dir.LockTimeout = lockTimeout
+	if to != StorageJSON && to != StorageDir {
+		return 0, fmt.Errorf("unknown storage %q (want %q or %q)", to, StorageJSON, StorageDir)
+	}
+	if _, err := os.Stat(path + ".journal"); err == nil {
+		return 0, errors.New("the task file has a journal; run compact with journal storage first")
+	}
+	if fileEncrypted(path) {
+		return 0, errors.New("directory storage does not support encrypted task files; decrypt the task file first")
+	}
+
+	unlock, err := file.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	_, statErr := os.Stat(path)
+	hasFile := statErr == nil
+	switch {
+	case to == StorageDir && !hasFile:
+		return 0, fmt.Errorf("there is no task file %s to migrate", path)
+	case to == StorageJSON && !dir.Exists():
+		return 0, fmt.Errorf("there is no task directory %s to migrate", dir.Dir)
+	case to == StorageDir && dir.Exists():
+		return 0, fmt.Errorf("task directory %s already exists", dir.Dir)
+	case to == StorageJSON && hasFile:
+		return 0, fmt.Errorf("task file %s already exists", path)
+	}
+
+	if to == StorageDir {
+		list, err := file.readRepaired()
+		if err != nil {
+			return 0, err
+		}
+		if err := dir.save(&TaskList{NextID: 1}, list, formatVersion); err != nil {
+			return 0, err
+		}
+		if err := os.Remove(path); err != nil {
+			return 0, err
+		}
+		return len(list.Tasks), nil
+	}
+
+	list, err := dir.Load()
+	if err != nil {
+		return 0, err
+	}
+	if err := file.save(list); err != nil {
+		return 0, err
+	}
+	if err := dir.remove(list); err != nil {
+		return 0, err
+	}
+	return len(list.Tasks), nil
+}
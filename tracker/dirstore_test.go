@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDirStore tests the one-file-per-task storage.
+//
+// The test includes the following cases:
+//
+//  1. Add: Add tasks. The test checks that each gets its own file and that
+//     the ID counter is kept in the metadata file.
+//
+//  2. Update: Mark a task as done. The test checks that only that task's
+//     file is rewritten.
+//
+//  3. Delete and purge: Delete a task and purge the trash. The test checks
+//     that the task's file is removed and its ID is not reused.
+//
+//  4. Older version: Load a directory of version 3 with a hand-edited
+//     status. The test checks that the status is migrated and that the next
+//     change rewrites the task file and the metadata in the current version.
+//
+//  5. Backups: The test checks that a config with backups and directory
+//     storage is refused.
+func TestDirStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	s := NewDirStore(path)
+	tr := New(s)
+
+	// Case 1: Tambah task
+	tr.Add("First")
+	tr.Add("Second")
+	for _, id := range []int{1, 2} {
+		if _, err := os.Stat(s.taskPath(id)); err != nil {
+			t.Errorf("Expected a file for task %d, got %v", id, err)
+		}
+	}
+	list, err := s.Load()
+	if err != nil || list.NextID != 3 || len(list.Tasks) != 2 {
+		t.Errorf("Expected 2 tasks and next ID 3, got %+v (%v)", list, err)
+	}
+
+	// Case 2: Ubah satu task
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(s.taskPath(1), old, old)
+	tr.Mark(2, "done")
+	if info, _ := os.Stat(s.taskPath(1)); !info.ModTime().Equal(old) {
+		t.Errorf("Expected the file of task 1 to be untouched, modified at %v", info.ModTime())
+	}
+	if task, err := s.Get(2); err != nil || task.Status != "done" {
+		t.Errorf("Expected task 2 to be done, got %+v (%v)", task, err)
+	}
+
+	// Case 3: Hapus dan purge task
+	tr.Delete(2)
+	tr.PurgeTrash(0)
+	if _, err := os.Stat(s.taskPath(2)); !os.IsNotExist(err) {
+		t.Errorf("Expected the file of task 2 to be removed, got %v", err)
+	}
+	tr.Add("Third")
+	if task, err := s.Get(3); err != nil || task.Description != "Third" {
+		t.Errorf("Expected the new task to get ID 3, got %+v (%v)", task, err)
+	}
+
+	// Case 4: Versi lama
+	os.WriteFile(filepath.Join(s.Dir, dirMetaFile), []byte(`{"version": 3, "meta": {"nextId": 4}}`), 0644)
+	os.WriteFile(s.taskPath(1), []byte(`{"id": 1, "uid": "a1", "description": "First", "status": "In Progress"}`), 0644)
+	if task, err := s.Get(1); err != nil || task.Status != StatusInProgress {
+		t.Errorf("Expected the status migrated to in-progress, got %+v (%v)", task, err)
+	}
+	tr.Add("Fourth")
+	var meta dirMeta
+	data, _ := os.ReadFile(filepath.Join(s.Dir, dirMetaFile))
+	if err := json.Unmarshal(data, &meta); err != nil || meta.Version != formatVersion || meta.Meta.NextID != 5 {
+		t.Errorf("Expected the metadata in version %d, got %s", formatVersion, data)
+	}
+	if data, _ := os.ReadFile(s.taskPath(1)); !strings.Contains(string(data), `"in-progress"`) {
+		t.Errorf("Expected the task file rewritten, got %s", data)
+	}
+
+	// Case 5: Backup
+	config := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("TASK_CLI_CONFIG", config)
+	os.WriteFile(config, []byte(`{"storage": "dir", "backups": {"keep": 3}}`), 0644)
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "backups") {
+		t.Errorf("Expected backups with directory storage to be refused, got %v", err)
+	}
+}
+
+// TestMigrateStorage tests converting between the task file and the task
+// directory.
+//
+// The test includes the following cases:
+//
+//  1. To directory: Migrate a task file with a trashed task. The test checks
+//     that the directory holds the same tasks and ID counter and that the
+//     task file is gone.
+//
+//  2. Twice: Migrate to the directory again. The test checks that it is
+//     refused.
+//
+//  3. Back to file: Migrate back. The test checks that the task file holds
+//     the same tasks and that the directory is gone.
+func TestMigrateStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	tr := New(NewJSONFileStore(path))
+	tr.Add("Keep")
+	tr.Add("Trash")
+	tr.Add("Purge")
+	tr.Delete(2)
+	tr.Delete(3)
+	tr.Restore(3)
+	tr.Store.Modify(func(list *TaskList) error {
+		list.Remove(3)
+		return nil
+	})
+	before, _ := tr.Store.Load()
+
+	// Case 1: Migrasi ke direktori
+	if _, err := MigrateStorage(path, StorageDir, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the task file to be removed, got %v", err)
+	}
+	dir := NewDirStore(path)
+	list, err := dir.Load()
+	if err != nil || list.NextID != before.NextID || len(DiffTaskLists(before, list)) != 0 {
+		t.Errorf("Expected %+v, got %+v (%v)", before, list, err)
+	}
+
+	// Case 2: Migrasi ulang ditolak
+	if _, err := MigrateStorage(path, StorageDir, 0); err == nil {
+		t.Error("Expected an error when migrating twice")
+	}
+
+	// Case 3: Migrasi kembali ke file
+	if _, err := MigrateStorage(path, StorageJSON, 0); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(dir.Dir); !os.IsNotExist(err) {
+		t.Errorf("Expected the task directory to be removed, got %v", err)
+	}
+	list, err = NewJSONFileStore(path).Load()
+	if err != nil || list.NextID != before.NextID || len(DiffTaskLists(before, list)) != 0 {
+		t.Errorf("Expected %+v, got %+v (%v)", before, list, err)
+	}
+}
@@ -0,0 +1,280 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// statusAliases maps spellings seen in hand-edited files to the status
+// they mean. Keys are lower case with spaces and underscores turned into
+// dashes.
+var statusAliases = map[string]Status{
+	"to-do":       "todo",
+	"open":        "todo",
+	"inprogress":  "in-progress",
+	"in-process":  "in-progress",
+	"doing":       "in-progress",
+	"started":     "in-progress",
+	"wip":         "in-progress",
+	"complete":    "done",
+	"completed":   "done",
+	"finished":    "done",
+	"closed":      "done",
+	"in-progress": "in-progress",
+	"todo":        "todo",
+	"done":        "done",
+}
+
+// emptyDescription replaces empty descriptions when repairing.
+const emptyDescription = "(no description)"
+
+// Problem is an integrity problem found by CheckTasks.
+type Problem struct {
+	// Index is the position of the offending task in the file.
+	Index int
+	Task  Task
+	Issue string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("task #%d (ID %d, %q): %s", p.Index+1, p.Task.ID, p.Task.Description, p.Issue)
+}
+
+// CheckTasks returns every integrity problem in list: duplicate IDs, IDs
+// below 1, statuses the workflow does not declare, empty or untrimmed
+// descriptions, UpdatedAt before CreatedAt and parents that are missing or
+// lead back to the task.
+func CheckTasks(list *TaskList, workflow Workflow) []Problem {
+	var problems []Problem
+	firstUse := make(map[int]int)
+	parents := parentIssues(list)
+	for i, task := range list.Tasks {
+		report := func(format string, args ...any) {
+			problems = append(problems, Problem{Index: i, Task: task, Issue: fmt.Sprintf(format, args...)})
+		}
+
+		if task.ID < 1 {
+			report("invalid ID %d", task.ID)
+		} else if first, ok := firstUse[task.ID]; ok {
+			report("duplicate ID %d (also used by task #%d)", task.ID, first+1)
+		} else {
+			firstUse[task.ID] = i
+		}
+		if !workflow.Has(task.Status) {
+			report("unknown status %q", task.Status)
+		}
+		if strings.TrimSpace(task.Description) == "" {
+			report("empty description")
+		} else if strings.TrimSpace(task.Description) != task.Description {
+			report("description has leading or trailing spaces")
+		}
+		if task.UpdatedAt.Before(task.CreatedAt) {
+			report("updatedAt %s is before createdAt %s", task.UpdatedAt.Format(time.RFC3339), task.CreatedAt.Format(time.RFC3339))
+		}
+		if issue, ok := parents[i]; ok {
+			report("%s", issue)
+		}
+	}
+	return problems
+}
+
+// parentIssues maps the indexes of the tasks of list whose parent is
+// missing or is the task itself or one of its subtasks to the problem.
+func parentIssues(list *TaskList) map[int]string {
+	parentOf := make(map[int]int, len(list.Tasks))
+	for _, task := range list.Tasks {
+		parentOf[task.ID] = task.ParentID
+	}
+	issues := make(map[int]string)
+	for i, task := range list.Tasks {
+		if task.ParentID == 0 {
+			continue
+		}
+		if _, ok := parentOf[task.ParentID]; !ok {
+			issues[i] = fmt.Sprintf("parent %d does not exist", task.ParentID)
+			continue
+		}
+		for parent, steps := task.ParentID, 0; parent != 0 && steps <= len(list.Tasks); parent, steps = parentOf[parent], steps+1 {
+			if parent == task.ID {
+				issues[i] = fmt.Sprintf("parent %d makes the task its own subtask", task.ParentID)
+				break
+			}
+		}
+	}
+	return issues
+}
+
+// normalizeStatus maps a misspelled status to one the workflow declares.
+// Statuses it cannot make sense of get the initial status.
+func normalizeStatus(status Status, workflow Workflow) Status {
+	key := strings.ToLower(strings.TrimSpace(string(status)))
+	key = strings.NewReplacer(" ", "-", "_", "-").Replace(key)
+	if workflow.Has(Status(key)) {
+		return Status(key)
+	}
+	if known, ok := statusAliases[key]; ok && workflow.Has(known) {
+		return known
+	}
+	return workflow.Initial()
+}
+
+// RepairTasks applies the safe repairs for the problems CheckTasks finds:
+// duplicate and invalid IDs get new IDs, statuses are normalized,
+// descriptions trimmed, UpdatedAt moved up to CreatedAt and bad parents
+// dropped, which makes the tasks top-level tasks. It returns a description
+// of each repair.
+func RepairTasks(list *TaskList, workflow Workflow) []string {
+	var repairs []string
+	for _, c := range list.repairDuplicateIDs() {
+		repairs = append(repairs, fmt.Sprintf("renumbered duplicate ID %d to %d (%q)", c.OldID, c.NewID, c.Description))
+	}
+	issues := parentIssues(list)
+	for i := range list.Tasks {
+		// Dropping one parent of a cycle settles the others
+		if _, ok := issues[i]; ok && parentIssues(list)[i] != "" {
+			task := &list.Tasks[i]
+			repairs = append(repairs, fmt.Sprintf("task %d: parent %d dropped", task.ID, task.ParentID))
+			task.ParentID = 0
+		}
+	}
+
+	for i := range list.Tasks {
+		task := &list.Tasks[i]
+		if task.ID < 1 {
+			old := task.ID
+			list.fixNextID()
+			task.ID = list.NextID
+			list.NextID++
+			repairs = append(repairs, fmt.Sprintf("renumbered invalid ID %d to %d (%q)", old, task.ID, task.Description))
+		}
+		if !workflow.Has(task.Status) {
+			old := task.Status
+			task.Status = normalizeStatus(old, workflow)
+			repairs = append(repairs, fmt.Sprintf("task %d: status %q set to %q", task.ID, old, task.Status))
+		}
+		if trimmed := strings.TrimSpace(task.Description); trimmed != task.Description {
+			task.Description = trimmed
+			repairs = append(repairs, fmt.Sprintf("task %d: description trimmed", task.ID))
+		}
+		if task.Description == "" {
+			task.Description = emptyDescription
+			repairs = append(repairs, fmt.Sprintf("task %d: empty description set to %q", task.ID, emptyDescription))
+		}
+		if task.UpdatedAt.Before(task.CreatedAt) {
+			task.UpdatedAt = task.CreatedAt
+			repairs = append(repairs, fmt.Sprintf("task %d: updatedAt set to createdAt", task.ID))
+		}
+	}
+	return repairs
+}
+
+// LoadUnrepaired loads the task list from s as it is stored, without the
+// automatic repair of duplicate IDs that Load does.
+func LoadUnrepaired(s TaskStore) (*TaskList, error) {
+	switch base := BaseStore(s).(type) {
+	case *JSONFileStore:
+		list, _, err := base.read()
+		return list, err
+	case *JournalStore:
+		list, _, err := base.Snapshot.read()
+		if err != nil {
+			return nil, err
+		}
+		return base.replay(list)
+	default:
+		return s.Load()
+	}
+}
+
+// DoctorReport is what Doctor found and, when asked to, repaired.
+type DoctorReport struct {
+	// Tasks is the number of tasks checked, including the trash.
+	Tasks int
+	// Problems are the problems found.
+	Problems []Problem
+	// Backup is the copy of the task file written before the repairs, if
+	// any.
+	Backup string
+	// Repairs describe the repairs made.
+	Repairs []string
+}
+
+// Doctor checks the task file at path, which the tracker's store holds,
+// for integrity problems. With fix it copies the tasks to the backup
+// directory (see backupDir) and then repairs them; stores it cannot back up
+// are not repaired.
+func (t *Tracker) Doctor(path string, fix bool) (DoctorReport, error) {
+	var report DoctorReport
+	list, err := LoadUnrepaired(t.Store)
+	if err != nil {
+		return report, err
+	}
+
+	report.Tasks = len(list.Tasks)
+	report.Problems = CheckTasks(list, t.workflow())
+	if len(report.Problems) == 0 || !fix {
+		return report, nil
+	}
+
+	if report.Backup, err = doctorBackup(t.Store, path, list); err != nil {
+		return report, err
+	}
+
+	// The store renumbers duplicate IDs as it loads the tasks for Modify,
+	// the same way RepairTasks would
+	if repairedOnLoad(t.Store) {
+		for _, c := range list.Clone().repairDuplicateIDs() {
+			report.Repairs = append(report.Repairs, fmt.Sprintf("renumbered duplicate ID %d to %d (%q)", c.OldID, c.NewID, c.Description))
+		}
+	}
+	err = t.Store.Modify(func(list *TaskList) error {
+		report.Repairs = append(report.Repairs, RepairTasks(list, t.workflow())...)
+		return nil
+	})
+	return report, err
+}
+
+// repairedOnLoad reports whether s renumbers duplicate IDs when it loads
+// the tasks, which LoadUnrepaired does not.
+func repairedOnLoad(s TaskStore) bool {
+	switch BaseStore(s).(type) {
+	case *JSONFileStore, *JournalStore:
+		return true
+	default:
+		return false
+	}
+}
+
+// doctorBackup copies the tasks of s, list as loaded by LoadUnrepaired, to
+// the backup directory of the task file at path and returns the copy. The
+// task file is copied as it is, encrypted or not; the tasks of a journal or
+// a task directory, which the file does not hold, are written as a task
+// file.
+func doctorBackup(s TaskStore, path string, list *TaskList) (string, error) {
+	var data []byte
+	var err error
+	switch BaseStore(s).(type) {
+	case *JSONFileStore:
+		data, err = os.ReadFile(path)
+	case *JournalStore, *DirStore:
+		data, err = encodeTaskList(list)
+	default:
+		err = errors.New("the store cannot be backed up")
+	}
+	if err != nil {
+		return "", fmt.Errorf("nothing repaired, as no backup could be written: %w", err)
+	}
+
+	backup := filepath.Join(backupDir(path), backupName(path, time.Now()))
+	if err := os.MkdirAll(backupDir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(backup, data, 0644); err != nil {
+		return "", fmt.Errorf("writing backup: %w", err)
+	}
+	return backup, nil
+}
@@ -0,0 +1,126 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCheckAndRepairTasks tests the integrity checks and repairs of doctor.
+//
+// The test includes the following cases:
+//
+//  1. Check: Check a list with a duplicate ID, a zero ID, an unknown status, an
+//     empty description and UpdatedAt before CreatedAt. The test checks that
+//     every problem is reported.
+//
+//  2. Repair: Repair the list. The test checks that IDs are unique, statuses
+//     are normalized, descriptions trimmed and timestamps fixed, and that the
+//     list has no problems left.
+func TestCheckAndRepairTasks(t *testing.T) {
+	created := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	list := &TaskList{Tasks: []Task{
+		{ID: 1, Description: "Fine", Status: "todo", CreatedAt: created, UpdatedAt: created},
+		{ID: 1, Description: "  Duplicate ", Status: "In Progress", CreatedAt: created, UpdatedAt: created},
+		{ID: 0, Description: "", Status: "completed", CreatedAt: created, UpdatedAt: created.Add(-time.Hour)},
+	}}
+
+	// Case 1: Periksa semua masalah
+	var issues []string
+	for _, p := range CheckTasks(list, DefaultWorkflow) {
+		issues = append(issues, p.Issue)
+	}
+	joined := strings.Join(issues, "; ")
+	for _, want := range []string{"duplicate ID 1", "invalid ID 0", `unknown status "In Progress"`, "leading or trailing spaces", "empty description", "before createdAt"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected problem %q, got %s", want, joined)
+		}
+	}
+
+	// Case 2: Perbaiki semua masalah
+	RepairTasks(list, DefaultWorkflow)
+	if problems := CheckTasks(list, DefaultWorkflow); len(problems) != 0 {
+		t.Fatalf("Expected no problems after repair, got %v", problems)
+	}
+	if list.Tasks[1].ID == 1 || list.Tasks[1].Status != "in-progress" || list.Tasks[1].Description != "Duplicate" {
+		t.Errorf("Unexpected repaired task: %+v", list.Tasks[1])
+	}
+	if list.Tasks[2].ID < 1 || list.Tasks[2].Status != "done" || list.Tasks[2].Description != emptyDescription {
+		t.Errorf("Unexpected repaired task: %+v", list.Tasks[2])
+	}
+}
+
+// TestDoctor tests checking and repairing a task file.
+//
+// The test includes the following cases:
+//
+//  1. Miscased status: Check a current task file with statuses written by
+//     hand and a duplicate ID. The test checks that the file loads and the
+//     problems are reported.
+//
+//  2. Fix: Repair the file. The test checks that a backup is written, the
+//     statuses are normalized and the renumbered ID is among the repairs.
+//
+//  3. Task directory: Repair a task in directory storage. The test checks
+//     that the tasks are backed up as a task file.
+//
+//  4. No backup: Repair a store that cannot be backed up. The test checks
+//     that nothing is repaired.
+func TestDoctor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"version": 4, "meta": {"nextId": 3}, "tasks": [
+		{"id": 1, "description": "One", "status": "Done"},
+		{"id": 2, "description": "Two", "status": "in progress"},
+		{"id": 2, "description": "Three", "status": "todo"}
+	]}`), 0644)
+	tr := New(NewJSONFileStore(path))
+
+	// Case 1: Status dengan huruf besar
+	report, err := tr.Doctor(path, false)
+	if err != nil || len(report.Problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %v (err %v)", report.Problems, err)
+	}
+
+	// Case 2: Perbaiki file
+	report, err = tr.Doctor(path, true)
+	if err != nil || report.Backup == "" {
+		t.Fatalf("Expected a backup, got %+v (err %v)", report, err)
+	}
+	if _, err := os.Stat(report.Backup); err != nil {
+		t.Errorf("Expected the backup to exist, got %v", err)
+	}
+	list, err := NewJSONFileStore(path).Load()
+	if err != nil || list.Tasks[0].Status != StatusDone || list.Tasks[1].Status != StatusInProgress {
+		t.Errorf("Expected done and in-progress, got %+v (err %v)", list, err)
+	}
+	if repairs := strings.Join(report.Repairs, "; "); !strings.Contains(repairs, "renumbered duplicate ID 2 to 3") {
+		t.Errorf("Expected the renumbered ID among the repairs, got %s", repairs)
+	}
+
+	// Case 3: Direktori task
+	dir := NewDirStore(filepath.Join(t.TempDir(), "tasks.json"))
+	os.MkdirAll(dir.Dir, 0755)
+	os.WriteFile(filepath.Join(dir.Dir, dirMetaFile), []byte(`{"version": 4, "meta": {"nextId": 2}}`), 0644)
+	os.WriteFile(dir.taskPath(1), []byte(`{"id": 1, "description": "One", "status": "Done"}`), 0644)
+	report, err = New(dir).Doctor(dir.Path, true)
+	if err != nil || report.Backup == "" {
+		t.Fatalf("Expected a backup, got %+v (err %v)", report, err)
+	}
+	if backup, err := NewJSONFileStore(report.Backup).Load(); err != nil || len(backup.Tasks) != 1 || backup.Tasks[0].Status != "Done" {
+		t.Errorf("Expected the task as it was in the backup, got %+v (err %v)", backup, err)
+	}
+	if task, _ := dir.Get(1); task.Status != StatusDone {
+		t.Errorf("Expected the status normalized, got %q", task.Status)
+	}
+
+	// Case 4: Tanpa backup
+	memory := NewMemoryStore(Task{ID: 1, Description: "One", Status: "Done"})
+	if _, err := New(memory).Doctor(path, true); err == nil {
+		t.Error("Expected an error without a backup")
+	}
+	if task, _ := memory.Get(1); task.Status != "Done" {
+		t.Errorf("Expected nothing repaired, got %q", task.Status)
+	}
+}
@@ -0,0 +1,99 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidDueDate is returned for due dates ParseDue does not understand.
+var ErrInvalidDueDate = errors.New("invalid due date")
+
+// weekdays maps the names and abbreviations of the days of the week to
+// them.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// ParseDue returns the day s names, relative to now, as midnight in now's
+// time zone. It accepts ISO dates ("2024-05-31") and these phrases, in any
+// case:
+//
+//   - "today", "tomorrow" and "yesterday"
+//   - a day of the week such as "fri" or "friday": the next such day,
+//     today included
+//   - "next" and a day of the week, e.g. "next monday": the next such day
+//     after today
+//   - "in N days", "in N weeks", "in N months" or "in N years"
+//   - "end of week" (the coming Sunday), "end of month" and "end of year"
+//
+// Due dates are days, not times: a task is due until the end of its day;
+// see Task.Overdue.
+func ParseDue(s string, now time.Time) (time.Time, error) {
+	phrase := strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if day, err := time.ParseInLocation("2006-01-02", phrase, now.Location()); err == nil {
+		return day, nil
+	}
+	switch phrase {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "end of week":
+		return today.AddDate(0, 0, (7-int(today.Weekday()))%7), nil
+	case "end of month":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()), nil
+	case "end of year":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, today.Location()), nil
+	}
+
+	if weekday, ok := weekdays[phrase]; ok {
+		return today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+7)%7), nil
+	}
+	if name, ok := strings.CutPrefix(phrase, "next "); ok {
+		if weekday, ok := weekdays[name]; ok {
+			return today.AddDate(0, 0, (int(weekday)-int(today.Weekday())+6)%7+1), nil
+		}
+	}
+	if rest, ok := strings.CutPrefix(phrase, "in "); ok {
+		if fields := strings.Fields(rest); len(fields) == 2 {
+			n, err := strconv.Atoi(fields[0])
+			if err == nil && n >= 0 {
+				switch strings.TrimSuffix(fields[1], "s") {
+				case "day":
+					return today.AddDate(0, 0, n), nil
+				case "week":
+					return today.AddDate(0, 0, 7*n), nil
+				case "month":
+					return today.AddDate(0, n, 0), nil
+				case "year":
+					return today.AddDate(n, 0, 0), nil
+				}
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w %q (try 2024-05-31, today, tomorrow, fri, next monday, in 3 days or end of month)", ErrInvalidDueDate, s)
+}
+
+// Overdue reports whether the task is open in workflow and the end of its
+// due day has passed at now.
+func (t Task) Overdue(now time.Time, workflow Workflow) bool {
+	if t.DueAt == nil || workflow.Closed(t.Status) {
+		return false
+	}
+	due := *t.DueAt
+	end := time.Date(due.Year(), due.Month(), due.Day()+1, 0, 0, 0, 0, due.Location())
+	return !now.Before(end)
+}
@@ -0,0 +1,112 @@
+package tracker
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestParseDue tests turning due date phrases into days.
+//
+// The test includes the following cases:
+//
+//  1. Phrases: Parse every kind of phrase on Wednesday 2024-05-15. The test
+//     checks the day each one gives.
+//
+//  2. Invalid: The test checks that phrases it does not know are refused
+//     with ErrInvalidDueDate.
+func TestParseDue(t *testing.T) {
+	now := time.Date(2024, 5, 15, 16, 30, 0, 0, time.UTC)
+
+	// Case 1: Frasa yang dikenal
+	for phrase, want := range map[string]string{
+		"2024-06-01":   "2024-06-01",
+		"today":        "2024-05-15",
+		"Tomorrow":     "2024-05-16",
+		"yesterday":    "2024-05-14",
+		"wed":          "2024-05-15",
+		"fri":          "2024-05-17",
+		"monday":       "2024-05-20",
+		"next wed":     "2024-05-22",
+		"next  friday": "2024-05-17",
+		"in 3 days":    "2024-05-18",
+		"in 1 week":    "2024-05-22",
+		"in 2 months":  "2024-07-15",
+		"end of week":  "2024-05-19",
+		"end of month": "2024-05-31",
+		"end of year":  "2024-12-31",
+	} {
+		got, err := ParseDue(phrase, now)
+		if err != nil || got.Format(time.DateOnly) != want || got.Hour() != 0 {
+			t.Errorf("ParseDue(%q) = %v (err %v), want %s", phrase, got, err, want)
+		}
+	}
+
+	// Case 2: Frasa yang tidak dikenal
+	for _, phrase := range []string{"", "someday", "in three days", "next month", "2024-13-01"} {
+		if _, err := ParseDue(phrase, now); !errors.Is(err, ErrInvalidDueDate) {
+			t.Errorf("Expected ErrInvalidDueDate for %q, got %v", phrase, err)
+		}
+	}
+}
+
+// TestDueQuery tests setting due dates and listing by them against a fixed
+// clock.
+//
+// The test includes the following cases:
+//
+//  1. Overdue: The test checks that only open tasks whose due day has ended
+//     are overdue, and that a task due today is not.
+//
+//  2. Due before: The test checks that DueBefore gives the tasks due before
+//     the day, leaving out tasks without a due date.
+//
+//  3. Sort and clear: The test checks that SortDue puts tasks without a due
+//     date last and that the zero time removes a due date.
+func TestDueQuery(t *testing.T) {
+	now := time.Date(2024, 5, 15, 16, 30, 0, 0, time.UTC)
+	tr := &Tracker{Store: NewMemoryStore(), Clock: func() time.Time { return now }}
+	day := func(phrase string) *time.Time {
+		due, err := ParseDue(phrase, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &due
+	}
+	tr.AddTask(Task{Description: "Late", DueAt: day("yesterday")})
+	tr.AddTask(Task{Description: "Today", DueAt: day("today")})
+	tr.AddTask(Task{Description: "Done late", DueAt: day("2024-05-01")})
+	tr.AddTask(Task{Description: "No due date"})
+	tr.AddTask(Task{Description: "Later", DueAt: day("next monday")})
+	tr.Mark(3, StatusDone)
+	ids := func(q Query) []int {
+		tasks, err := tr.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	// Case 1: Task yang terlambat
+	if got := ids(Query{Overdue: true}); !slices.Equal(got, []int{1}) {
+		t.Errorf("Expected task 1 overdue, got %v", got)
+	}
+
+	// Case 2: Jatuh tempo sebelum tanggal tertentu
+	if got := ids(Query{DueBefore: day("tomorrow"), Sort: SortID}); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Expected tasks 1, 2 and 3, got %v", got)
+	}
+
+	// Case 3: Urutan dan menghapus tanggal
+	if got := ids(Query{Sort: SortDue}); !slices.Equal(got, []int{3, 1, 2, 5, 4}) {
+		t.Errorf("Expected 3, 1, 2, 5, 4 by due date, got %v", got)
+	}
+	if task, err := tr.UpdateTask(5, TaskUpdate{DueAt: &time.Time{}}); err != nil || task.DueAt != nil {
+		t.Errorf("Expected no due date, got %+v (err %v)", task, err)
+	}
+}
@@ -0,0 +1,418 @@
+package tracker
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Encrypted task files hold the task file, in the usual format, sealed with
+// AES-256-GCM under a key derived from a passphrase with PBKDF2-HMAC-SHA256:
+//
+//	{"encrypted":{"cipher":"aes-256-gcm","kdf":"pbkdf2-sha256","iterations":N,"salt":"...","nonce":"..."},"data":"..."}
+//
+// The header is authenticated along with the data, so tampering with the
+// KDF parameters is detected like tampering with the tasks.
+const (
+	cipherAES256GCM = "aes-256-gcm"
+	kdfPBKDF2SHA256 = "pbkdf2-sha256"
+)
+
+// encryptedPrefix starts every encrypted file; see isEncrypted.
+var encryptedPrefix = []byte(`{"encrypted":`)
+
+// pbkdf2Iterations is the PBKDF2 work factor used for new files. Files
+// record their own, so it can be raised without breaking existing ones.
+var pbkdf2Iterations = 600000
+
+// PassphraseEnv names the environment variable the passphrase is taken
+// from before prompting on the terminal.
+const PassphraseEnv = "TASK_CLI_PASSPHRASE"
+
+var (
+	// errWrongPassphrase is returned when an encrypted file does not
+	// decrypt, which is either a wrong passphrase or a damaged file.
+	errWrongPassphrase = errors.New("wrong passphrase or damaged encrypted file")
+	// errNoPassphrase is returned for encrypted files when there is no
+	// way to get a passphrase.
+	errNoPassphrase = errors.New("the task file is encrypted; set " + PassphraseEnv + " or run task-cli on a terminal")
+)
+
+// encryptionHeader describes how an encrypted file was sealed.
+type encryptionHeader struct {
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+}
+
+// encryptedFile is the on-disk form of an encrypted file.
+type encryptedFile struct {
+	Encrypted encryptionHeader `json:"encrypted"`
+	Data      []byte           `json:"data"`
+}
+
+// isEncrypted reports whether data is an encrypted file. Only the prefix
+// is checked, so callers can tell from the start of a file.
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), encryptedPrefix)
+}
+
+// fileEncrypted reports whether the file at path is encrypted. A missing
+// or unreadable file is not.
+func fileEncrypted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 64)
+	n, _ := f.Read(head)
+	return isEncrypted(head[:n])
+}
+
+// Encryption seals and opens files with a key derived from a passphrase.
+// The passphrase is asked for at most once and the derived key is reused
+// for files with the same salt, so the slow key derivation runs once per
+// process. It is safe for concurrent use.
+type Encryption struct {
+	// Passphrase returns the passphrase. It is called the first time a
+	// file is sealed or opened.
+	Passphrase func() (string, error)
+
+	mu         sync.Mutex
+	passphrase string
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// keyFor returns the key for the given salt and work factor, deriving it
+// if it is not the cached one. The caller must hold e.mu.
+func (e *Encryption) keyFor(salt []byte, iterations int) ([]byte, error) {
+	if e.key != nil && bytes.Equal(salt, e.salt) && iterations == e.iterations {
+		return e.key, nil
+	}
+	if e.passphrase == "" {
+		if e.Passphrase == nil {
+			return nil, errNoPassphrase
+		}
+		passphrase, err := e.Passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if passphrase == "" {
+			return nil, errors.New("the passphrase cannot be empty")
+		}
+		e.passphrase = passphrase
+	}
+	e.key = pbkdf2SHA256([]byte(e.passphrase), salt, iterations, 32)
+	e.salt = salt
+	e.iterations = iterations
+	return e.key, nil
+}
+
+// seal encrypts data. It reuses the salt of the last file opened, so files
+// written back keep their key, and picks a new salt otherwise.
+func (e *Encryption) seal(data []byte) ([]byte, error) {
+	if e == nil {
+		return nil, errNoPassphrase
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	salt, iterations := e.salt, e.iterations
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		iterations = pbkdf2Iterations
+	}
+	key, err := e.keyFor(salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := encryptionHeader{
+		Cipher:     cipherAES256GCM,
+		KDF:        kdfPBKDF2SHA256,
+		Iterations: iterations,
+		Salt:       salt,
+		Nonce:      make([]byte, gcm.NonceSize()),
+	}
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return nil, err
+	}
+	ad, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encryptedFile{
+		Encrypted: header,
+		Data:      gcm.Seal(nil, header.Nonce, data, ad),
+	})
+}
+
+// open decrypts an encrypted file.
+func (e *Encryption) open(data []byte) ([]byte, error) {
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	header := file.Encrypted
+	if header.Cipher != cipherAES256GCM || header.KDF != kdfPBKDF2SHA256 {
+		return nil, fmt.Errorf("unsupported encryption %s with %s", header.Cipher, header.KDF)
+	}
+	if header.Iterations <= 0 {
+		return nil, errors.New("invalid encryption header")
+	}
+	if e == nil {
+		return nil, errNoPassphrase
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key, err := e.keyFor(header.Salt, header.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(header.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid encryption header")
+	}
+	ad, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, header.Nonce, file.Data, ad)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plain, nil
+}
+
+// openIfEncrypted decrypts data if it is an encrypted file and returns it
+// unchanged otherwise.
+func (e *Encryption) openIfEncrypted(data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	return e.open(data)
+}
+
+// newGCM returns AES-GCM with the given key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password and salt with
+// PBKDF2 (RFC 8018) using HMAC-SHA256.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		key = append(key, pbkdf2Block(prf, salt, iterations, block)...)
+	}
+	return key[:keyLen]
+}
+
+// pbkdf2Block computes block number block of the PBKDF2 output.
+func pbkdf2Block(prf hash.Hash, salt []byte, iterations int, block uint32) []byte {
+	prf.Reset()
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, block))
+	u := prf.Sum(nil)
+	t := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range t {
+			t[j] ^= u[j]
+		}
+	}
+	return t
+}
+
+// PassphrasePrompt returns a function that takes the passphrase from
+// PassphraseEnv or, if that is not set, asks for it on the terminal. With
+// confirm the passphrase has to be typed twice, for choosing a new one.
+func PassphrasePrompt(confirm bool) func() (string, error) {
+	return func() (string, error) {
+		if passphrase, ok := os.LookupEnv(PassphraseEnv); ok {
+			return passphrase, nil
+		}
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return "", errNoPassphrase
+		}
+		defer tty.Close()
+
+		passphrase, err := readPassphrase(tty, "Passphrase: ")
+		if err != nil || !confirm {
+			return passphrase, err
+		}
+		again, err := readPassphrase(tty, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("the passphrases do not match")
+		}
+		return passphrase, nil
+	}
+}
+
+// readPassphrase prompts on tty and reads a line with echo turned off.
+// Where stty is not available the passphrase is echoed.
+func readPassphrase(tty *os.File, prompt string) (string, error) {
+	fmt.Fprint(tty, prompt)
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	if stty("-echo") == nil {
+		defer stty("echo")
+	}
+	line, err := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// encryptedFiles returns the files that hold the tasks of s: the task file,
+// its last good copy, the damaged copy left by RestoreBackup, the copies
+// kept from format upgrades, the rolling backups and the undo history.
+func encryptedFiles(s TaskStore) []string {
+	file := TaskFileStore(s)
+	paths := []string{file.Path, file.backupPath(), file.corruptPath()}
+	if matches, _ := filepath.Glob(file.Path + ".v*.bak"); matches != nil {
+		paths = append(paths, matches...)
+	}
+	if backups, err := ListBackups(file.Path); err == nil {
+		for _, b := range backups {
+			paths = append(paths, filepath.Join(backupDir(file.Path), b.Name))
+		}
+	}
+	if history, ok := s.(*HistoryStore); ok {
+		paths = append(paths, history.Path)
+	}
+	return paths
+}
+
+// convertTaskFiles runs convert on every existing file of s that is not
+// yet in the wanted state, under the locks of the history and the task
+// file. It returns the number of files converted.
+func convertTaskFiles(s TaskStore, encrypted bool, convert func(data []byte) ([]byte, error)) (int, error) {
+	file := TaskFileStore(s)
+	if file == nil {
+		return 0, errors.New("the store is not backed by a task file")
+	}
+	if _, ok := BaseStore(s).(*JournalStore); ok {
+		return 0, errJournalEncrypted
+	}
+	if history, ok := s.(*HistoryStore); ok {
+		unlock, err := lockFile(history.Path+".lock", lockTimeout(history.TaskStore))
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+	}
+	unlock, err := file.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	converted := 0
+	for _, path := range encryptedFiles(s) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return converted, err
+		}
+		if isEncrypted(data) == encrypted {
+			continue
+		}
+		if data, err = convert(data); err != nil {
+			return converted, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeFileAtomic(path, data, 0644); err != nil {
+			return converted, err
+		}
+		converted++
+	}
+	return converted, nil
+}
+
+// EncryptTaskFile encrypts the task file of s together with the copies of
+// it task-cli keeps (see encryptedFiles), using the passphrase of the task
+// file's Encryption. Once encrypted, the task file stays encrypted when it
+// is saved. A missing task file is created empty, so that the first tasks
+// are already encrypted. It returns the number of files encrypted.
+func EncryptTaskFile(s TaskStore) (int, error) {
+	file := TaskFileStore(s)
+	if file != nil && fileEncrypted(file.Path) {
+		return 0, errors.New("the task file is already encrypted")
+	}
+	if file != nil {
+		if _, err := os.Stat(file.Path); os.IsNotExist(err) {
+			if err := file.Save(&TaskList{NextID: 1, Tasks: []Task{}}); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var enc *Encryption
+	if file != nil {
+		enc = file.Encryption
+	}
+	return convertTaskFiles(s, true, enc.seal)
+}
+
+// DecryptTaskFile turns the task file of s and its copies back into plain
+// JSON. It returns the number of files decrypted.
+func DecryptTaskFile(s TaskStore) (int, error) {
+	file := TaskFileStore(s)
+	if file != nil && !fileEncrypted(file.Path) {
+		return 0, errors.New("the task file is not encrypted")
+	}
+
+	var enc *Encryption
+	if file != nil {
+		enc = file.Encryption
+	}
+	return convertTaskFiles(s, false, enc.open)
+}
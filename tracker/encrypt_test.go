@@ -0,0 +1,119 @@
+package tracker
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPBKDF2 tests the key derivation against the published PBKDF2-HMAC-SHA256
+// test vectors.
+func TestPBKDF2(t *testing.T) {
+	tests := []struct {
+		iterations int
+		want       string
+	}{
+		{1, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{2, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{4096, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte("password"), []byte("salt"), tt.iterations, 32))
+		if got != tt.want {
+			t.Errorf("%d iterations: expected %s, got %s", tt.iterations, tt.want, got)
+		}
+	}
+}
+
+// TestEncryptedStore tests encrypting and decrypting a task file.
+//
+// The test includes the following cases:
+//
+//  1. Encrypt: Encrypt a task file with tasks, history and a damaged copy
+//     left by restoring the backup. The test checks that no description is
+//     left in plain text in any of the files and that the tasks still load.
+//
+//  2. Save: Add a task. The test checks that the file stays encrypted and
+//     that undo still works on the encrypted history.
+//
+//  3. Wrong passphrase: Open the file with another passphrase. The test
+//     checks that errWrongPassphrase is returned and that it is not reported
+//     as a damaged file.
+//
+//  4. Decrypt: Decrypt the file. The test checks that it is plain JSON again
+//     and keeps its tasks.
+func TestEncryptedStore(t *testing.T) {
+	defer func(n int) { pbkdf2Iterations = n }(pbkdf2Iterations)
+	pbkdf2Iterations = 1000
+	passphrase := func() (string, error) { return "s3cret", nil }
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	open := func(passphrase func() (string, error)) TaskStore {
+		s, err := OpenStore(path, Config{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		TaskFileStore(s).Encryption.Passphrase = passphrase
+		return s
+	}
+	tr := New(open(passphrase))
+	tr.Add("Customer incident")
+	tr.Mark(1, "done")
+
+	// Case 1: Enkripsi file task
+	os.WriteFile(path+".corrupt", []byte(`{"tasks": [{"description": "Customer incident"`), 0644)
+	if _, err := EncryptTaskFile(tr.Store); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, name := range []string{path, path + ".bak", path + ".corrupt", path + ".history"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist, got %v", name, err)
+		}
+		if !isEncrypted(data) || strings.Contains(string(data), "Customer") {
+			t.Errorf("Expected %s to be encrypted, got %s", name, data)
+		}
+	}
+	tr.Store = open(passphrase)
+	tasks, err := tr.Tasks()
+	if err != nil || len(tasks) != 1 || tasks[0].Description != "Customer incident" {
+		t.Errorf("Expected the task to load, got %+v (%v)", tasks, err)
+	}
+
+	// Case 2: Simpan ke file terenkripsi
+	tr.Add("Second incident")
+	if !fileEncrypted(path) || !fileEncrypted(path+".history") {
+		t.Error("Expected the task file and history to stay encrypted")
+	}
+	if _, err := tr.Store.(*HistoryStore).Undo(1); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if tasks, _ := tr.Tasks(); len(tasks) != 1 {
+		t.Errorf("Expected 1 task after undo, got %+v", tasks)
+	}
+
+	// Case 3: Passphrase salah
+	tr.Store = open(func() (string, error) { return "wrong", nil })
+	_, err = tr.Tasks()
+	var cerr *CorruptFileError
+	if !errors.Is(err, errWrongPassphrase) || errors.As(err, &cerr) {
+		t.Errorf("Expected errWrongPassphrase, got %v", err)
+	}
+
+	// Case 4: Dekripsi file task
+	tr.Store = open(passphrase)
+	if _, err := DecryptTaskFile(tr.Store); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fileEncrypted(path) || fileEncrypted(path+".history") {
+		t.Error("Expected the files to be decrypted")
+	}
+	tr.Store = NewJSONFileStore(path)
+	tasks, err = tr.Tasks()
+	if err != nil || len(tasks) != 1 || tasks[0].Status != "done" {
+		t.Errorf("Expected the task to be kept, got %+v (%v)", tasks, err)
+	}
+}
@@ -0,0 +1,316 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// formatVersion is the task file format written by this version of
+// task-cli. Files with a lower version are upgraded through migrations when
+// they are loaded; files with a higher version are refused.
+//
+// The versions so far:
+//
+//	0: a bare JSON array of tasks
+//	1: {"nextId": N, "tasks": [...]}
+//	2: {"version": 2, "meta": {"nextId": N}, "tasks": [...]}
+//	3: as 2, with task UIDs and "tombstones" in "meta"; see Sync
+//	4: as 3, with well-formed status names only; see Status
+const formatVersion = 4
+
+// taskFile is the on-disk form of a TaskList.
+type taskFile struct {
+	Version int      `json:"version"`
+	Meta    taskMeta `json:"meta"`
+	Tasks   []Task   `json:"tasks"`
+}
+
+// taskMeta holds the task file's metadata.
+type taskMeta struct {
+	NextID     int         `json:"nextId"`
+	Tombstones []Tombstone `json:"tombstones,omitempty"`
+}
+
+// migration upgrades the raw JSON of a task file from version From to
+// version From+1. Migrations work on raw JSON rather than on Task so that
+// they keep working when Task changes.
+type migration struct {
+	From    int
+	Migrate func(data []byte) ([]byte, error)
+}
+
+// migrations is the registry of format upgrades, one per version step.
+var migrations = []migration{
+	{From: 0, Migrate: migrateBareArray},
+	{From: 1, Migrate: migrateToEnvelope},
+	{From: 2, Migrate: migrateToUIDs},
+	{From: 3, Migrate: migrateStatuses},
+}
+
+// FileVersionError is returned for task files written by a newer task-cli.
+type FileVersionError struct {
+	Version int
+}
+
+func (e *FileVersionError) Error() string {
+	return fmt.Sprintf("file is newer than this binary: it uses format version %d, but this task-cli only understands up to version %d; please upgrade task-cli", e.Version, formatVersion)
+}
+
+// detectVersion returns the format version of a task file.
+func detectVersion(data []byte) (int, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return 0, nil
+	}
+
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	if header.Version == nil {
+		return 1, nil
+	}
+	return *header.Version, nil
+}
+
+// migrateTaskFile upgrades the raw JSON of a task file to formatVersion. It
+// returns the upgraded JSON and the version the file had.
+func migrateTaskFile(data []byte) ([]byte, int, error) {
+	from, err := detectVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if from > formatVersion {
+		return nil, from, &FileVersionError{Version: from}
+	}
+
+	for _, m := range migrations {
+		if m.From < from {
+			continue
+		}
+		if data, err = m.Migrate(data); err != nil {
+			return nil, from, fmt.Errorf("upgrading task file from version %d: %w", m.From, err)
+		}
+	}
+	return data, from, nil
+}
+
+// migrateBareArray wraps the version 0 task array in an object and adds
+// the ID counter.
+func migrateBareArray(data []byte) ([]byte, error) {
+	var tasks []json.RawMessage
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return nil, err
+	}
+	nextID := 1
+	for _, raw := range tasks {
+		var task struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &task); err != nil {
+			return nil, err
+		}
+		if task.ID >= nextID {
+			nextID = task.ID + 1
+		}
+	}
+	if tasks == nil {
+		tasks = []json.RawMessage{}
+	}
+	return json.Marshal(map[string]any{"nextId": nextID, "tasks": tasks})
+}
+
+// migrateToEnvelope moves the version 1 ID counter into "meta" and adds
+// the version number.
+func migrateToEnvelope(data []byte) ([]byte, error) {
+	var v1 struct {
+		NextID int             `json:"nextId"`
+		Tasks  json.RawMessage `json:"tasks"`
+	}
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return nil, err
+	}
+	if v1.Tasks == nil {
+		v1.Tasks = json.RawMessage("[]")
+	}
+	return json.Marshal(map[string]any{
+		"version": 2,
+		"meta":    map[string]any{"nextId": v1.NextID},
+		"tasks":   v1.Tasks,
+	})
+}
+
+// migrateToUIDs only bumps the version: tasks without a UID get one derived
+// on use (see Task.uid), and older binaries must not drop the UIDs and
+// tombstones this version adds.
+func migrateToUIDs(data []byte) ([]byte, error) {
+	var v2 map[string]json.RawMessage
+	if err := json.Unmarshal(data, &v2); err != nil {
+		return nil, err
+	}
+	v2["version"] = json.RawMessage("3")
+	return json.Marshal(v2)
+}
+
+// migrateStatuses turns the statuses that are not in DefaultWorkflow, which
+// older binaries accepted from hand-edited files, into known ones the way
+// doctor repairs them (see normalizeStatus), so that the file can be
+// decoded.
+func migrateStatuses(data []byte) ([]byte, error) {
+	var v3 map[string]json.RawMessage
+	if err := json.Unmarshal(data, &v3); err != nil {
+		return nil, err
+	}
+	var tasks []map[string]json.RawMessage
+	if err := json.Unmarshal(v3["tasks"], &tasks); err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		var status string
+		if err := json.Unmarshal(task["status"], &status); err != nil || !DefaultWorkflow.Has(Status(status)) {
+			task["status"], _ = json.Marshal(normalizeStatus(Status(status), DefaultWorkflow))
+		}
+	}
+	if tasks == nil {
+		tasks = []map[string]json.RawMessage{}
+	}
+	v3["version"] = json.RawMessage("4")
+	v3["tasks"], _ = json.Marshal(tasks)
+	return json.Marshal(v3)
+}
+
+// decodeTaskList parses a task file of any supported version. It also
+// returns the version the file was in.
+func decodeTaskList(data []byte) (*TaskList, int, error) {
+	list, from, _, err := decodeTaskFile(data)
+	return list, from, err
+}
+
+// encodedTask is a task as it was read, together with its JSON in the file.
+type encodedTask struct {
+	task Task
+	raw  []byte
+}
+
+// currentHeader starts every task file written by encodeTaskList.
+var currentHeader = []byte(fmt.Sprintf("{\n  \"version\": %d,\n", formatVersion))
+
+// decodeTaskFile is decodeTaskList, but also returns the JSON of every task
+// by ID if the file was written by encodeTaskList, for
+// encodeTaskListReusing.
+func decodeTaskFile(data []byte) (*TaskList, int, map[int]encodedTask, error) {
+	if isEncrypted(data) {
+		return nil, 0, nil, errors.New("the file is encrypted")
+	}
+
+	// Files written by this version need no migration, and so no version
+	// detection, which parses the whole file a second time
+	from := formatVersion
+	var file taskFile
+	if !bytes.HasPrefix(data, currentHeader) || json.Unmarshal(data, &file) != nil || file.Version != formatVersion {
+		var err error
+		if data, from, err = migrateTaskFile(data); err != nil {
+			return nil, from, nil, err
+		}
+		file = taskFile{}
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, from, nil, err
+		}
+	}
+
+	list := &TaskList{NextID: file.Meta.NextID, Tasks: file.Tasks, Tombstones: file.Meta.Tombstones}
+	if list.Tasks == nil {
+		list.Tasks = []Task{}
+	}
+	list.fixNextID()
+	var encoded map[int]encodedTask
+	if from == formatVersion {
+		encoded = splitTasks(data, list.Tasks)
+	}
+	return list, from, encoded, nil
+}
+
+// splitTasks finds the JSON of each of tasks in data, the task file they
+// were decoded from, and returns it by ID. It returns nil unless data is
+// laid out as encodeTaskList lays it out: there every task starts with a
+// line "    {" and ends with a line "    }", as strings cannot hold line
+// breaks.
+func splitTasks(data []byte, tasks []Task) map[int]encodedTask {
+	const open, end = "\n    {\n      \"id\": ", "\n    }"
+	i := bytes.Index(data, []byte("\n  \"tasks\": ["))
+	if i < 0 {
+		return nil
+	}
+	rest := data[i+len("\n  \"tasks\": ["):]
+
+	encoded := make(map[int]encodedTask, len(tasks))
+	var prefix []byte
+	for _, task := range tasks {
+		prefix = strconv.AppendInt(append(prefix[:0], open...), int64(task.ID), 10)
+		if !bytes.HasPrefix(rest, prefix) {
+			return nil
+		}
+		n := bytes.Index(rest, []byte(end))
+		if n < 0 {
+			return nil
+		}
+		encoded[task.ID] = encodedTask{task: task, raw: rest[len("\n    ") : n+len(end)]}
+		rest = bytes.TrimPrefix(rest[n+len(end):], []byte(","))
+	}
+	if !bytes.HasPrefix(rest, []byte("\n  ]")) {
+		return nil
+	}
+	return encoded
+}
+
+// encodeTaskList returns the current on-disk form of list.
+func encodeTaskList(list *TaskList) ([]byte, error) {
+	return encodeTaskListReusing(list, nil)
+}
+
+// encodeTaskListReusing is encodeTaskList, but copies the JSON of the tasks
+// that are the same as in encoded instead of encoding them again; with
+// large lists, encoding takes most of the time of a save. The result is the
+// same as json.MarshalIndent of the whole file.
+func encodeTaskListReusing(list *TaskList, encoded map[int]encodedTask) ([]byte, error) {
+	meta, err := json.MarshalIndent(taskMeta{NextID: list.NextID, Tombstones: list.Tombstones}, "  ", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(meta) + 256*len(list.Tasks))
+	fmt.Fprintf(&buf, "{\n  \"version\": %d,\n  \"meta\": %s,\n  \"tasks\": ", formatVersion, meta)
+	switch {
+	case list.Tasks == nil:
+		buf.WriteString("null")
+	case len(list.Tasks) == 0:
+		buf.WriteString("[]")
+	default:
+		buf.WriteByte('[')
+		for i, task := range list.Tasks {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString("\n    ")
+			if e, ok := encoded[task.ID]; ok && reflect.DeepEqual(e.task, task) {
+				buf.Write(e.raw)
+				continue
+			}
+			raw, err := json.MarshalIndent(task, "    ", "  ")
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(raw)
+		}
+		buf.WriteString("\n  ]")
+	}
+	buf.WriteString("\n}")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFormatMigrations tests that older task files are upgraded on load.
+//
+// The test includes the following cases:
+//
+//  1. Version 0: Load a bare task array. The test checks that the tasks and the
+//     next ID survive, that the file is saved in the current format and that
+//     the original is kept as a backup.
+//
+//  2. Version 1: Load a {"nextId", "tasks"} object. The test checks that the
+//     stored next ID is kept.
+//
+//  3. Newer version: Load a file with a version above formatVersion. The test
+//     checks that a *FileVersionError is returned and the file is untouched.
+func TestFormatMigrations(t *testing.T) {
+	dir := t.TempDir()
+
+	// Case 1: Versi 0 (array)
+	path := filepath.Join(dir, "v0.json")
+	v0 := `[{"id": 2, "description": "Old task", "status": "todo"}]`
+	os.WriteFile(path, []byte(v0), 0644)
+	list, err := NewJSONFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(list.Tasks) != 1 || list.Tasks[0].Description != "Old task" || list.NextID != 3 {
+		t.Errorf("Unexpected list after upgrade: %+v", list)
+	}
+	data, _ := os.ReadFile(path)
+	if version, _ := detectVersion(data); version != formatVersion {
+		t.Errorf("Expected file saved as version %d, got %d", formatVersion, version)
+	}
+	if backup, err := os.ReadFile(path + ".v0.bak"); err != nil || string(backup) != v0 {
+		t.Errorf("Expected original kept as backup, got %q (err %v)", backup, err)
+	}
+
+	// Case 2: Versi 1 (object tanpa version)
+	path = filepath.Join(dir, "v1.json")
+	os.WriteFile(path, []byte(`{"nextId": 10, "tasks": [{"id": 2, "description": "Task", "status": "done"}]}`), 0644)
+	list, err = NewJSONFileStore(path).Load()
+	if err != nil || list.NextID != 10 || len(list.Tasks) != 1 {
+		t.Errorf("Unexpected list after upgrade: %+v (err %v)", list, err)
+	}
+
+	// Case 3: Versi lebih baru dari binary ini
+	path = filepath.Join(dir, "future.json")
+	future := `{"version": 99, "meta": {}, "tasks": []}`
+	os.WriteFile(path, []byte(future), 0644)
+	_, err = NewJSONFileStore(path).Load()
+	var verr *FileVersionError
+	if !errors.As(err, &verr) || !strings.Contains(err.Error(), "newer than this binary") {
+		t.Fatalf("Expected FileVersionError, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != future {
+		t.Errorf("Expected newer file to be left untouched, got %s", data)
+	}
+}
+
+// TestEncodeTaskList tests that saving reuses the JSON of unchanged tasks
+// without changing the output.
+//
+// The test includes the following cases:
+//
+//  1. Layout: Encode a list with tasks in the trash and tombstones. The test
+//     checks that the result equals json.MarshalIndent of the whole file.
+//
+//  2. Reuse: Decode the result, change one task and encode it reusing the
+//     JSON read. The test checks that the result equals a fresh encoding.
+//
+//  3. Other layouts: Decode a compact file. The test checks that its JSON is
+//     not kept, so that saving writes the usual layout.
+func TestEncodeTaskList(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	list := &TaskList{NextID: 4, Tombstones: []Tombstone{{UID: "aa", At: now}}}
+	for _, d := range []string{"One", "Two <b>", "Three"} {
+		list.Add(Task{Description: d, Status: "todo", CreatedAt: now, UpdatedAt: now})
+	}
+	list.Tasks[1].DeletedAt = &now
+
+	// Case 1: Layout sama dengan MarshalIndent
+	data, err := encodeTaskList(list)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want, _ := json.MarshalIndent(taskFile{Version: formatVersion, Meta: taskMeta{NextID: list.NextID, Tombstones: list.Tombstones}, Tasks: list.Tasks}, "", "  ")
+	if string(data) != string(want) {
+		t.Errorf("Expected\n%s\ngot\n%s", want, data)
+	}
+
+	// Case 2: JSON task yang tidak berubah dipakai ulang
+	decoded, _, encoded, err := decodeTaskFile(data)
+	if err != nil || len(encoded) != 3 {
+		t.Fatalf("Expected 3 encoded tasks, got %d (err %v)", len(encoded), err)
+	}
+	decoded.Tasks[2].Status = "done"
+	reused, _ := encodeTaskListReusing(decoded, encoded)
+	if fresh, _ := encodeTaskList(decoded); string(reused) != string(fresh) {
+		t.Errorf("Expected\n%s\ngot\n%s", fresh, reused)
+	}
+
+	// Case 3: Layout lain tidak dipakai ulang
+	compact, _ := json.Marshal(taskFile{Version: formatVersion, Meta: taskMeta{NextID: 4}, Tasks: list.Tasks})
+	if _, _, encoded, err := decodeTaskFile(compact); err != nil || len(encoded) != 0 {
+		t.Errorf("Expected no encoded tasks, got %d (err %v)", len(encoded), err)
+	}
+}
@@ -0,0 +1,285 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of changes undo can walk back when the
+// config does not say otherwise.
+const DefaultHistoryLimit = 50
+
+var (
+	// errNothingToUndo is returned by Undo when the history is exhausted.
+	errNothingToUndo = errors.New("nothing to undo")
+	// errNothingToRedo is returned by Redo when no undone change remains.
+	errNothingToRedo = errors.New("nothing to redo")
+)
+
+// historyEntry is one recorded change: the affected tasks before and after
+// it.
+type historyEntry struct {
+	At      time.Time      `json:"at"`
+	Changes []historyTasks `json:"changes"`
+}
+
+// historyTasks is the state of one task before and after a change. Before
+// is nil for added tasks and After is nil for purged ones.
+type historyTasks struct {
+	Before *Task `json:"before,omitempty"`
+	After  *Task `json:"after,omitempty"`
+}
+
+// historyFile is the on-disk form of the history. Entries before Position
+// can be undone, entries from Position on can be redone.
+type historyFile struct {
+	Position int            `json:"position"`
+	Entries  []historyEntry `json:"entries"`
+}
+
+// HistoryStore wraps a TaskStore and records the before and after state of
+// every change made through Modify or Save in a history file, so that
+// changes can be undone and redone. At most Limit changes are kept.
+//
+// The history has its own lock, taken before the wrapped store's, so that
+// the order of the entries matches the order of the changes even when
+// several task-cli processes run at once.
+type HistoryStore struct {
+	TaskStore
+	Path  string
+	Limit int
+}
+
+// NewHistoryStore wraps inner, keeping the history at path.
+func NewHistoryStore(inner TaskStore, path string, limit int) *HistoryStore {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	return &HistoryStore{TaskStore: inner, Path: path, Limit: limit}
+}
+
+// Unwrap returns the wrapped store.
+func (s *HistoryStore) Unwrap() TaskStore {
+	return s.TaskStore
+}
+
+// Save replaces the task list and records the change.
+func (s *HistoryStore) Save(list *TaskList) error {
+	return s.Modify(func(current *TaskList) error {
+		*current = *list.Clone()
+		return nil
+	})
+}
+
+// Modify runs fn through the wrapped store and records what it changed.
+// Redo entries are dropped once a new change is made.
+func (s *HistoryStore) Modify(fn func(list *TaskList) error) error {
+	return s.withHistory(func(h *historyFile) error {
+		var entry historyEntry
+		err := s.TaskStore.Modify(func(list *TaskList) error {
+			before := list.Clone()
+			if err := fn(list); err != nil {
+				return err
+			}
+			entry = newHistoryEntry(DiffTaskLists(before, list))
+			return nil
+		})
+		if err != nil || len(entry.Changes) == 0 {
+			return err
+		}
+
+		h.Entries = append(h.Entries[:h.Position], entry)
+		if len(h.Entries) > s.Limit {
+			h.Entries = h.Entries[len(h.Entries)-s.Limit:]
+		}
+		h.Position = len(h.Entries)
+		return nil
+	})
+}
+
+// newHistoryEntry turns the changes of one Modify into a history entry.
+func newHistoryEntry(changes []TaskChange) historyEntry {
+	entry := historyEntry{At: time.Now()}
+	for _, c := range changes {
+		entry.Changes = append(entry.Changes, historyTasks{Before: c.Before, After: c.After})
+	}
+	return entry
+}
+
+// Undo reverts the last steps changes that have not been undone yet and
+// returns a description of each, most recent first. It stops early, with
+// an error, if the history runs out or a task has changed since.
+func (s *HistoryStore) Undo(steps int) ([]string, error) {
+	return s.walk(steps, errNothingToUndo, func(h *historyFile) (apply, recorded historyEntry, ok bool) {
+		if h.Position == 0 {
+			return historyEntry{}, historyEntry{}, false
+		}
+		h.Position--
+		return h.Entries[h.Position].reversed(), h.Entries[h.Position], true
+	})
+}
+
+// Redo reapplies the last steps undone changes and returns a description of
+// each.
+func (s *HistoryStore) Redo(steps int) ([]string, error) {
+	return s.walk(steps, errNothingToRedo, func(h *historyFile) (apply, recorded historyEntry, ok bool) {
+		if h.Position == len(h.Entries) {
+			return historyEntry{}, historyEntry{}, false
+		}
+		h.Position++
+		return h.Entries[h.Position-1], h.Entries[h.Position-1], true
+	})
+}
+
+// walk applies up to steps entries picked by next, which also moves the
+// history position and returns both the entry to apply and the recorded
+// entry it stems from.
+func (s *HistoryStore) walk(steps int, exhausted error, next func(h *historyFile) (apply, recorded historyEntry, ok bool)) ([]string, error) {
+	var done []string
+	err := s.withHistory(func(h *historyFile) error {
+		for len(done) < steps {
+			position := h.Position
+			entry, recorded, ok := next(h)
+			if !ok {
+				if len(done) == 0 {
+					return exhausted
+				}
+				return nil
+			}
+			if err := s.TaskStore.Modify(entry.applyTo); err != nil {
+				h.Position = position
+				return err
+			}
+			done = append(done, recorded.describe())
+		}
+		return nil
+	})
+	return done, err
+}
+
+// reversed returns the entry that undoes e.
+func (e historyEntry) reversed() historyEntry {
+	r := historyEntry{At: e.At}
+	for i := len(e.Changes) - 1; i >= 0; i-- {
+		c := e.Changes[i]
+		r.Changes = append(r.Changes, historyTasks{Before: c.After, After: c.Before})
+	}
+	return r
+}
+
+// applyTo moves every task of the entry from its Before to its After state.
+// It refuses if a task is no longer in its Before state, since the change
+// would then overwrite something not recorded in the history.
+func (e historyEntry) applyTo(list *TaskList) error {
+	for _, c := range e.Changes {
+		id := c.id()
+		i := list.Index(id)
+		switch {
+		case c.Before == nil && i >= 0, c.Before != nil && (i < 0 || !sameTask(list.Tasks[i], *c.Before)):
+			return fmt.Errorf("task %d has changed since; the history cannot be applied", id)
+		}
+	}
+	for _, c := range e.Changes {
+		if c.After == nil {
+			list.Remove(c.id())
+		} else {
+			list.Put(*c.After)
+		}
+	}
+	return nil
+}
+
+// describe summarizes the entry, e.g. `deleted task 12 "Buy milk"`.
+func (e historyEntry) describe() string {
+	var parts []string
+	for _, c := range e.Changes {
+		switch {
+		case c.Before == nil:
+			parts = append(parts, fmt.Sprintf("added task %d %q", c.After.ID, c.After.Description))
+		case c.After == nil:
+			parts = append(parts, fmt.Sprintf("purged task %d %q", c.Before.ID, c.Before.Description))
+		case !c.Before.Trashed() && c.After.Trashed():
+			parts = append(parts, fmt.Sprintf("deleted task %d %q", c.After.ID, c.After.Description))
+		case c.Before.Trashed() && !c.After.Trashed():
+			parts = append(parts, fmt.Sprintf("restored task %d %q", c.After.ID, c.After.Description))
+		case c.Before.Status != c.After.Status && c.Before.Description == c.After.Description:
+			parts = append(parts, fmt.Sprintf("marked task %d %q as %s", c.After.ID, c.After.Description, c.After.Status))
+		default:
+			parts = append(parts, fmt.Sprintf("updated task %d %q", c.After.ID, c.After.Description))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// id returns the ID of the task.
+func (c historyTasks) id() int {
+	if c.After != nil {
+		return c.After.ID
+	}
+	return c.Before.ID
+}
+
+// withHistory runs fn on the history under the history lock and saves the
+// history afterwards, even if fn fails part way. The history holds copies
+// of tasks, so it is encrypted whenever the task file is.
+func (s *HistoryStore) withHistory(fn func(h *historyFile) error) error {
+	unlock, err := lockFile(s.Path+".lock", lockTimeout(s.TaskStore))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var enc *Encryption
+	file := TaskFileStore(s.TaskStore)
+	if file != nil {
+		enc = file.Encryption
+	}
+
+	var h historyFile
+	data, err := os.ReadFile(s.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if data, err = enc.openIfEncrypted(data); err != nil {
+			return fmt.Errorf("reading history %s: %w", s.Path, err)
+		}
+		if err := json.Unmarshal(data, &h); err != nil {
+			return fmt.Errorf("reading history %s: %w", s.Path, err)
+		}
+	}
+	if h.Position > len(h.Entries) {
+		h.Position = len(h.Entries)
+	}
+
+	fnErr := fn(&h)
+	data, err = json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if file != nil && fileEncrypted(file.Path) {
+		if data, err = enc.seal(data); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(s.Path, data, 0644); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// BaseStore returns the store underneath any wrapping stores such as
+// HistoryStore.
+func BaseStore(s TaskStore) TaskStore {
+	for {
+		wrapper, ok := s.(interface{ Unwrap() TaskStore })
+		if !ok {
+			return s
+		}
+		s = wrapper.Unwrap()
+	}
+}
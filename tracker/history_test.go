@@ -0,0 +1,76 @@
+package tracker
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestUndoRedo tests undoing and redoing changes through HistoryStore.
+//
+// The test includes the following cases:
+//
+//  1. Undo delete: Delete a task and undo. The test checks that the task is back
+//     with its original ID and that the undone change is described.
+//
+//  2. Undo and redo several steps: Undo the mark and the update, then redo one.
+//     The test checks the task's state after each step.
+//
+//  3. New change drops redo: Add a task after undoing. The test checks that redo
+//     reports "nothing to redo".
+//
+//  4. History limit: Make more changes than the limit. The test checks that undo
+//     stops after Limit steps.
+func TestUndoRedo(t *testing.T) {
+	dir := t.TempDir()
+	history := NewHistoryStore(NewJSONFileStore(filepath.Join(dir, "tasks.json")), filepath.Join(dir, "tasks.json.history"), 3)
+	tr := New(history)
+
+	tr.Add("Write report")
+	tr.Update(1, "Write better report")
+	tr.Mark(1, "done")
+	tr.Delete(1)
+
+	// Case 1: Undo penghapusan task
+	done, err := history.Undo(1)
+	if err != nil || len(done) != 1 || !strings.Contains(done[0], "deleted task 1") {
+		t.Fatalf("Expected delete to be undone, got %v (err %v)", done, err)
+	}
+	task, err := tr.Store.Get(1)
+	if err != nil || task.Status != "done" {
+		t.Fatalf("Expected task 1 restored as done, got %+v (err %v)", task, err)
+	}
+
+	// Case 2: Undo dua langkah lalu redo satu langkah
+	if _, err := history.Undo(2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	task, _ = tr.Store.Get(1)
+	if task.Description != "Write report" || task.Status != "todo" {
+		t.Errorf("Expected original task after undo, got %+v", task)
+	}
+	if _, err := history.Redo(1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	task, _ = tr.Store.Get(1)
+	if task.Description != "Write better report" || task.Status != "todo" {
+		t.Errorf("Expected updated task after redo, got %+v", task)
+	}
+
+	// Case 3: Perubahan baru menghapus redo
+	tr.Add("Another task")
+	if _, err := history.Redo(1); err != errNothingToRedo {
+		t.Fatalf("Expected errNothingToRedo, got %v", err)
+	}
+
+	// Case 4: Batas jumlah history
+	tr.Add("Third")
+	tr.Add("Fourth")
+	done, err = history.Undo(10)
+	if err != nil || len(done) != 3 {
+		t.Fatalf("Expected 3 undone changes, got %v (err %v)", done, err)
+	}
+	if _, err := history.Undo(1); err != errNothingToUndo {
+		t.Fatalf("Expected errNothingToUndo, got %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package tracker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// errJournalEncrypted is returned when journal storage is used with an
+// encrypted task file.
+var errJournalEncrypted = errors.New("encryption is not supported with journal storage; decrypt the task file or use json storage")
+
+// journalEvent is one line of the journal: a single change to one task, or
+// the tombstones after a change that replaying the task events would not
+// reproduce, such as tombstones taken over by sync.
+type journalEvent struct {
+	Type       string      `json:"type"`
+	TaskID     int         `json:"taskId"`
+	Task       *Task       `json:"task,omitempty"` // The task after the change; nil for purges
+	Tombstones []Tombstone `json:"tombstones,omitempty"`
+	At         time.Time   `json:"at"`
+}
+
+// journalTombstones is the type of the events that set the tombstones.
+const journalTombstones = "tombstones"
+
+// JournalStore keeps the task list as a snapshot file plus an append-only
+// journal of changes next to it (the snapshot path with a ".journal"
+// suffix). Each change appends one event per touched task instead of
+// rewriting the whole list; the current state is the snapshot with the
+// journal replayed on top. Compact folds the journal into the snapshot.
+//
+// Replaying an event sets a task to the recorded state, so replaying an
+// event twice is harmless. That keeps the store consistent if Compact is
+// interrupted after writing the snapshot but before clearing the journal.
+//
+// The order of tasks in the list is not recorded: added tasks are appended.
+type JournalStore struct {
+	Snapshot *JSONFileStore
+	Path     string
+}
+
+// NewJournalStore returns a journal store on top of the given snapshot.
+func NewJournalStore(snapshot *JSONFileStore) *JournalStore {
+	return &JournalStore{Snapshot: snapshot, Path: snapshot.Path + ".journal"}
+}
+
+// archivePath is where Compact keeps the events it folded into the
+// snapshot, so the audit trail is never lost.
+func (s *JournalStore) archivePath() string {
+	return s.Path + ".archive"
+}
+
+// Load returns the snapshot with the journal replayed on top.
+func (s *JournalStore) Load() (*TaskList, error) {
+	list, err := s.Snapshot.Load()
+	if err != nil {
+		return nil, err
+	}
+	return s.replay(list)
+}
+
+// load is Load for callers that already hold the lock. The journal is
+// plain JSON, so it refuses to write on top of an encrypted snapshot.
+func (s *JournalStore) load() (*TaskList, error) {
+	if fileEncrypted(s.Snapshot.Path) {
+		return nil, errJournalEncrypted
+	}
+	list, err := s.Snapshot.readRepaired()
+	if err != nil {
+		return nil, err
+	}
+	return s.replay(list)
+}
+
+// replay applies the journal's events to the snapshot list.
+func (s *JournalStore) replay(list *TaskList) (*TaskList, error) {
+	events, err := s.readEvents()
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		event.apply(list)
+	}
+	return list, nil
+}
+
+// readEvents reads the journal. A missing journal has no events. A last
+// line without a newline is the remains of an interrupted append and is
+// ignored.
+func (s *JournalStore) readEvents() ([]journalEvent, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if i := bytes.LastIndexByte(data, '\n'); i < len(data)-1 {
+		data = data[:i+1]
+	}
+
+	var events []journalEvent
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var event journalEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", s.Path, line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// apply replays the event on list. Journals written before the trash
+// existed record deletes without a task; those remove the task for good.
+func (e journalEvent) apply(list *TaskList) {
+	i := list.Index(e.TaskID)
+	switch {
+	case e.Type == journalTombstones:
+		list.Tombstones = append([]Tombstone(nil), e.Tombstones...)
+	case e.Task == nil:
+		if e.Type == ChangePurge || e.Type == ChangeDelete {
+			list.removeAt(e.TaskID, e.At)
+		}
+	case i >= 0:
+		list.Tasks[i] = *e.Task
+	default:
+		list.Tasks = append(list.Tasks, *e.Task)
+	}
+	list.fixNextID()
+}
+
+// Save records the changes from the current state to list in the journal.
+func (s *JournalStore) Save(list *TaskList) error {
+	return s.Modify(func(current *TaskList) error {
+		*current = *list.Clone()
+		return nil
+	})
+}
+
+// Get returns the task with the given ID.
+func (s *JournalStore) Get(id int) (Task, error) {
+	list, err := s.Load()
+	if err != nil {
+		return Task{}, err
+	}
+	return getTask(list, id)
+}
+
+// Modify runs fn on the current task list and appends an event for every
+// task it changed, plus one for the tombstones if the task events do not
+// account for them. It holds the snapshot's file lock throughout.
+func (s *JournalStore) Modify(fn func(list *TaskList) error) error {
+	unlock, err := s.Snapshot.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, err := s.load()
+	if err != nil {
+		return err
+	}
+	after := before.Clone()
+	if err := fn(after); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var events []journalEvent
+	replayed := before.Clone()
+	for _, change := range DiffTaskLists(before, after) {
+		event := journalEvent{Type: change.Kind, TaskID: change.ID(), Task: change.After, At: now}
+		event.apply(replayed)
+		events = append(events, event)
+	}
+	if !sameTombstones(replayed.Tombstones, after.Tombstones) {
+		events = append(events, journalEvent{Type: journalTombstones, Tombstones: after.Tombstones, At: now})
+	}
+	var buf bytes.Buffer
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	if err := s.trimTail(); err != nil {
+		return err
+	}
+	return appendFile(s.Path, buf.Bytes())
+}
+
+// trimTail cuts the remains of an interrupted append off the end of the
+// journal, so that the next event starts on a line of its own. The caller
+// must hold the lock.
+func (s *JournalStore) trimTail() error {
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return os.Truncate(s.Path, int64(bytes.LastIndexByte(data, '\n')+1))
+}
+
+// Compact writes the current state to the snapshot, moves the journal's
+// events to the archive and clears the journal. It returns the number of
+// events folded into the snapshot.
+func (s *JournalStore) Compact() (int, error) {
+	unlock, err := s.Snapshot.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	list, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	events, err := s.readEvents()
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	if err := s.Snapshot.save(list); err != nil {
+		return 0, err
+	}
+
+	journal, err := os.ReadFile(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	// Leave out the remains of an interrupted append
+	journal = journal[:bytes.LastIndexByte(journal, '\n')+1]
+	if err := appendFile(s.archivePath(), journal); err != nil {
+		return 0, err
+	}
+	if err := os.Remove(s.Path); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+// RestoreBackup restores the snapshot's last good copy.
+func (s *JournalStore) RestoreBackup() error {
+	return s.Snapshot.RestoreBackup()
+}
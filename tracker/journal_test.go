@@ -0,0 +1,104 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestJournalStore tests the append-only journal storage.
+//
+// The test includes the following cases:
+//
+//  1. Commands append events: Run add, update, mark and delete. The test checks
+//     that one event of the right type is appended per command and that the
+//     snapshot file is not written.
+//
+//  2. Interrupted append: Add a half-written last line. The test checks that it
+//     is ignored when the journal is replayed and that the next add leaves a
+//     journal that still loads.
+//
+//  3. Compact: Compact the journal. The test checks that the snapshot holds the
+//     current state, the journal is gone and its events are archived.
+//
+//  4. Replay after interrupted compact: Put the archived events back into the
+//     journal. The test checks that replaying them again gives the same state.
+//
+//  5. Tombstones: Take over a tombstone for a task this list never had, as
+//     sync does. The test checks that the journal keeps it.
+func TestJournalStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	journal := NewJournalStore(NewJSONFileStore(path))
+	tr := New(journal)
+
+	// Case 1: Setiap command menambah event ke journal
+	tr.Add("First")
+	tr.Add("Second")
+	tr.Update(1, "First, updated")
+	tr.Mark(2, "done")
+	tr.Delete(1)
+
+	events, err := journal.readEvents()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, ","); got != "add,add,update,mark,delete" {
+		t.Errorf("Expected events add,add,update,mark,delete, got %s", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected snapshot not to be written before compact")
+	}
+
+	// Case 2: Baris terakhir yang terpotong
+	appendFile(journal.Path, []byte(`{"type":"add","taskId":9,"ta`))
+	list, err := journal.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if live := list.Live(); len(live) != 1 || live[0].ID != 2 || live[0].Status != "done" || list.NextID != 3 {
+		t.Fatalf("Unexpected state after replay: %+v", list)
+	}
+	tr.Add("Third")
+	list, err = journal.Load()
+	if err != nil || len(list.Live()) != 2 || list.Live()[1].Description != "Third" {
+		t.Fatalf("Expected the task added after the half-written line, got %+v (err %v)", list, err)
+	}
+
+	// Case 3: Compact journal ke snapshot
+	n, err := journal.Compact()
+	if err != nil || n != 6 {
+		t.Fatalf("Expected 6 events compacted, got %d (err %v)", n, err)
+	}
+	snapshot, err := NewJSONFileStore(path).Load()
+	if err != nil || len(snapshot.Tasks) != 3 || len(snapshot.Trash()) != 1 {
+		t.Fatalf("Unexpected snapshot: %+v (err %v)", snapshot, err)
+	}
+	if _, err := os.Stat(journal.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected journal to be removed after compact")
+	}
+
+	// Case 4: Replay ulang event yang sudah di-compact
+	archived, _ := os.ReadFile(journal.archivePath())
+	os.WriteFile(journal.Path, archived, 0644)
+	list, err = journal.Load()
+	if err != nil || len(list.Live()) != 2 || list.Live()[0].ID != 2 {
+		t.Fatalf("Unexpected state after replaying twice: %+v (err %v)", list, err)
+	}
+
+	// Case 5: Tombstone
+	gone := Tombstone{UID: "gone", At: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	journal.Modify(func(list *TaskList) error {
+		list.Tombstones = append(list.Tombstones, gone)
+		return nil
+	})
+	list, err = NewJournalStore(NewJSONFileStore(path)).Load()
+	if err != nil || len(list.Tombstones) != 1 || list.Tombstones[0].UID != "gone" {
+		t.Errorf("Expected the tombstone kept in the journal, got %+v (err %v)", list.Tombstones, err)
+	}
+}
@@ -0,0 +1,65 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalTaskFile is the name of a per-directory task file. Like git's .git
+// directory it is found by searching up from the working directory.
+const LocalTaskFile = ".tasks.json"
+
+// TaskFileLocation is the resolved task file and why it was chosen.
+type TaskFileLocation struct {
+	Path   string
+	Reason string
+}
+
+// ResolveTaskFile decides which task file to use. The first of these wins:
+//
+//  1. flagPath, the value of the global --file flag
+//  2. the TASK_CLI_FILE environment variable
+//  3. a .tasks.json in cwd or one of its parent directories
+//  4. $XDG_DATA_HOME/task-cli/tasks.json, where XDG_DATA_HOME defaults to
+//     ~/.local/share
+func ResolveTaskFile(flagPath, cwd string) (TaskFileLocation, error) {
+	if flagPath != "" {
+		return absLocation(flagPath, cwd, "set by the --file flag"), nil
+	}
+	if env := os.Getenv("TASK_CLI_FILE"); env != "" {
+		return absLocation(env, cwd, "set by the TASK_CLI_FILE environment variable"), nil
+	}
+
+	for dir := cwd; ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, LocalTaskFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return TaskFileLocation{Path: path, Reason: fmt.Sprintf("found %s searching up from %s", LocalTaskFile, cwd)}, nil
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	reason := "default location under $XDG_DATA_HOME"
+	if !filepath.IsAbs(dataHome) {
+		// The XDG spec says relative paths are invalid and must be ignored
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return TaskFileLocation{}, errors.New("cannot determine the task file location: set --file, TASK_CLI_FILE or XDG_DATA_HOME")
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+		reason = "default location (~/.local/share, XDG_DATA_HOME is not set)"
+	}
+	return TaskFileLocation{Path: filepath.Join(dataHome, "task-cli", "tasks.json"), Reason: reason}, nil
+}
+
+// absLocation makes path absolute relative to cwd.
+func absLocation(path, cwd, reason string) TaskFileLocation {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cwd, path)
+	}
+	return TaskFileLocation{Path: filepath.Clean(path), Reason: reason}
+}
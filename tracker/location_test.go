@@ -0,0 +1,61 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestResolveTaskFile tests the resolution order of ResolveTaskFile.
+//
+// The test includes the following cases:
+//
+//  1. XDG default: Nothing else is set. The test checks that the file under
+//     $XDG_DATA_HOME/task-cli is used.
+//
+//  2. Discovery: A .tasks.json exists in a parent directory. The test checks
+//     that it is found from a nested working directory.
+//
+//  3. Environment variable: TASK_CLI_FILE is set. The test checks that it wins
+//     over the discovered file and is made absolute.
+//
+//  4. Flag: The --file flag is given. The test checks that it wins over
+//     everything else.
+func TestResolveTaskFile(t *testing.T) {
+	root := t.TempDir()
+	dataHome := filepath.Join(root, "data")
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "src", "pkg")
+	os.MkdirAll(nested, 0755)
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("TASK_CLI_FILE", "")
+
+	// Case 1: Lokasi default XDG
+	loc, err := ResolveTaskFile("", nested)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if loc.Path != filepath.Join(dataHome, "task-cli", "tasks.json") {
+		t.Errorf("Expected XDG default, got %s", loc.Path)
+	}
+
+	// Case 2: .tasks.json ditemukan di direktori induk
+	os.WriteFile(filepath.Join(project, ".tasks.json"), []byte("[]"), 0644)
+	loc, _ = ResolveTaskFile("", nested)
+	if loc.Path != filepath.Join(project, ".tasks.json") {
+		t.Errorf("Expected discovered file, got %s (%s)", loc.Path, loc.Reason)
+	}
+
+	// Case 3: TASK_CLI_FILE diset
+	t.Setenv("TASK_CLI_FILE", "env.json")
+	loc, _ = ResolveTaskFile("", nested)
+	if loc.Path != filepath.Join(nested, "env.json") {
+		t.Errorf("Expected TASK_CLI_FILE, got %s (%s)", loc.Path, loc.Reason)
+	}
+
+	// Case 4: Flag --file diberikan
+	loc, _ = ResolveTaskFile("/tmp/flag.json", nested)
+	if loc.Path != "/tmp/flag.json" {
+		t.Errorf("Expected --file path, got %s (%s)", loc.Path, loc.Reason)
+	}
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package tracker
+
+import (
+	"os"
+)
+
+// tryLockFile takes the lock by exclusively creating the file at path. It
+// reports false if the file already exists. Unlike flock the lock is not
+// released if the process dies, so a stale lock file has to be removed by
+// hand.
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	f.Close()
+	return func() {
+		os.Remove(path)
+	}, true, nil
+}
@@ -0,0 +1,95 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentAdders tests that parallel task-cli processes adding tasks to
+// the same file do not lose updates.
+//
+// The test includes the following cases:
+//
+//  1. Parallel adders: Start many processes that each add one task. The test
+//     checks that every task ends up in the file with a unique ID.
+//
+//  2. Lock timeout: Hold the lock and try to modify the store with a short
+//     timeout. The test checks that an errLockTimeout error is returned.
+//
+//  3. History lock timeout: Hold the history lock and undo through a store
+//     with a short timeout. The test checks that undo gives up after that
+//     timeout rather than the default one.
+func TestConcurrentAdders(t *testing.T) {
+	if path := os.Getenv("TASK_CLI_TEST_ADDER"); path != "" {
+		// Dijalankan sebagai proses helper: tambah satu task lalu keluar
+		tr := New(&JSONFileStore{Path: path, LockTimeout: time.Minute})
+		if _, err := tr.Add(fmt.Sprintf("Task from pid %d", os.Getpid())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	// Case 1: Banyak proses menambah task secara paralel
+	const adders = 20
+	var wg sync.WaitGroup
+	for i := 0; i < adders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(os.Args[0], "-test.run=^TestConcurrentAdders$")
+			cmd.Env = append(os.Environ(), "TASK_CLI_TEST_ADDER="+path)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("Adder failed: %v\n%s", err, out)
+			}
+		}()
+	}
+	wg.Wait()
+
+	list, err := NewJSONFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("Failed to load tasks: %v", err)
+	}
+	if len(list.Tasks) != adders {
+		t.Fatalf("Expected %d tasks, got %d", adders, len(list.Tasks))
+	}
+	seen := make(map[int]bool)
+	for _, task := range list.Tasks {
+		if seen[task.ID] {
+			t.Errorf("Duplicate task ID %d", task.ID)
+		}
+		seen[task.ID] = true
+	}
+
+	// Case 2: Lock dipegang proses lain
+	unlock, err := lockFile(path+".lock", time.Second)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer unlock()
+	s := &JSONFileStore{Path: path, LockTimeout: 50 * time.Millisecond}
+	err = s.Modify(func(list *TaskList) error { return nil })
+	if !errors.Is(err, errLockTimeout) {
+		t.Fatalf("Expected lock timeout error, got %v", err)
+	}
+
+	// Case 3: Lock history dipegang proses lain
+	history := NewHistoryStore(s, path+".history", 0)
+	unlockHistory, err := lockFile(history.Path+".lock", time.Second)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer unlockHistory()
+	start := time.Now()
+	if _, err := history.Undo(1); !errors.Is(err, errLockTimeout) || time.Since(start) > DefaultLockTimeout/2 {
+		t.Errorf("Expected a lock timeout after 50ms, got %v after %s", err, time.Since(start))
+	}
+}
@@ -0,0 +1,294 @@
+package tracker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// mergeDriverName is the name the merge driver is registered under in the
+// git config and .gitattributes.
+const mergeDriverName = "task-cli"
+
+// MergeResult is the outcome of MergeTaskLists.
+type MergeResult struct {
+	List *TaskList
+	// Renumbered lists tasks added on their side that were given a new ID
+	// because our side added a different task with the same ID.
+	Renumbered []IDChange
+	// Resolved describes fields changed differently on both sides, which
+	// were settled in favour of the side with the later UpdatedAt.
+	Resolved []string
+	// Conflicts describes tasks deleted on one side and changed on the
+	// other. The changed task is kept, and not deleted.
+	Conflicts []string
+}
+
+// MergeTaskLists merges the task lists ours and theirs, which were both
+// derived from base, by task ID:
+//
+//   - Tasks changed on one side take that side's version. Tasks changed on
+//     both sides are merged field by field; a field changed differently on
+//     both sides takes the value of the side whose UpdatedAt is later.
+//   - Tasks added on both sides with the same ID are kept once if they are
+//     the same; otherwise their task is given the next free ID, and the
+//     subtasks they added follow it.
+//   - Tasks deleted (moved to the trash or purged) on one side go if the
+//     other side left them alone. If the other side changed them, the
+//     changed task is kept and the conflict is reported.
+//
+// The merged tasks are in our order, followed by the tasks only they
+// added.
+func MergeTaskLists(base, ours, theirs *TaskList) MergeResult {
+	baseByID := tasksByID(base)
+	theirsByID := tasksByID(theirs)
+	oursByID := tasksByID(ours)
+
+	merged := &TaskList{NextID: max(base.NextID, ours.NextID, theirs.NextID), Tasks: []Task{}}
+	result := MergeResult{List: merged}
+
+	for _, task := range ours.Tasks {
+		old, inBase := baseByID[task.ID]
+		other, inTheirs := theirsByID[task.ID]
+		switch {
+		case !inBase:
+			// Added on our side
+			merged.Tasks = append(merged.Tasks, task)
+		case !inTheirs:
+			// Purged on their side
+			if len(changedFields(old, task)) > 0 {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("task %d (%s) was removed on their side but changed on ours; it is kept", task.ID, task.Description))
+				merged.Tasks = append(merged.Tasks, task)
+			}
+		default:
+			m, conflict := mergeTask(old, task, other, &result.Resolved)
+			if conflict != "" {
+				result.Conflicts = append(result.Conflicts, conflict)
+			}
+			merged.Tasks = append(merged.Tasks, m)
+		}
+	}
+
+	// added are the indexes in merged of the tasks added on their side
+	var added []int
+	for _, task := range theirs.Tasks {
+		old, inBase := baseByID[task.ID]
+		mine, inOurs := oursByID[task.ID]
+		switch {
+		case inBase && !inOurs:
+			// Purged on our side
+			if len(changedFields(old, task)) > 0 {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("task %d (%s) was removed on our side but changed on theirs; it is kept", task.ID, task.Description))
+				merged.Tasks = append(merged.Tasks, task)
+			}
+		case inBase:
+			// Merged above
+		case !inOurs:
+			// Added on their side only
+			added = append(added, len(merged.Tasks))
+			merged.Tasks = append(merged.Tasks, task)
+		case !sameTask(mine, task):
+			// Added on both sides with the same ID
+			result.Renumbered = append(result.Renumbered, IDChange{OldID: task.ID, NewID: merged.NextID, Description: task.Description})
+			task.ID = merged.NextID
+			merged.NextID++
+			added = append(added, len(merged.Tasks))
+			merged.Tasks = append(merged.Tasks, task)
+		}
+	}
+	// Subtasks added on their side follow their renumbered parents
+	for _, i := range added {
+		for _, c := range result.Renumbered {
+			if merged.Tasks[i].ParentID == c.OldID {
+				merged.Tasks[i].ParentID = c.NewID
+				break
+			}
+		}
+	}
+
+	merged.Tombstones = mergeTombstones(merged.Tasks, ours.Tombstones, theirs.Tombstones)
+	merged.fixNextID()
+	return result
+}
+
+// tasksByID indexes the tasks of list by ID.
+func tasksByID(list *TaskList) map[int]Task {
+	byID := make(map[int]Task, len(list.Tasks))
+	for _, task := range list.Tasks {
+		byID[task.ID] = task
+	}
+	return byID
+}
+
+// changedFields returns the names of the fields of task that differ from
+// base, apart from its ID, timestamps and trash state.
+func changedFields(base, task Task) []string {
+	var names []string
+	bv, tv := reflect.ValueOf(base), reflect.ValueOf(task)
+	for i := 0; i < bv.NumField(); i++ {
+		name := bv.Type().Field(i).Name
+		switch name {
+		case "ID", "CreatedAt", "UpdatedAt", "DeletedAt":
+			continue
+		}
+		if !reflect.DeepEqual(bv.Field(i).Interface(), tv.Field(i).Interface()) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mergeTask merges the changes ours and theirs made to the task base, one
+// field at a time. Fields changed differently on both sides are described
+// in resolved. Deleting the task on one side while changing it on the other
+// is a conflict: the task is kept, not deleted, and the conflict described.
+func mergeTask(base, ours, theirs Task, resolved *[]string) (Task, string) {
+	merged := base
+	theirsNewer := theirs.UpdatedAt.After(ours.UpdatedAt)
+
+	mv, bv := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(base)
+	ov, tv := reflect.ValueOf(ours), reflect.ValueOf(theirs)
+	for i := 0; i < mv.NumField(); i++ {
+		name := mv.Type().Field(i).Name
+		if name == "ID" || name == "CreatedAt" || name == "UpdatedAt" {
+			continue
+		}
+		b, o, t := bv.Field(i).Interface(), ov.Field(i).Interface(), tv.Field(i).Interface()
+		oChanged, tChanged := !reflect.DeepEqual(b, o), !reflect.DeepEqual(b, t)
+		switch {
+		case oChanged && tChanged && !reflect.DeepEqual(o, t):
+			side := "ours"
+			if theirsNewer {
+				side = "theirs"
+				mv.Field(i).Set(tv.Field(i))
+			} else {
+				mv.Field(i).Set(ov.Field(i))
+			}
+			*resolved = append(*resolved, fmt.Sprintf("task %d: %s changed on both sides; kept %s, which is newer", base.ID, name, side))
+		case oChanged:
+			mv.Field(i).Set(ov.Field(i))
+		case tChanged:
+			mv.Field(i).Set(tv.Field(i))
+		}
+	}
+
+	merged.UpdatedAt = ours.UpdatedAt
+	if theirsNewer {
+		merged.UpdatedAt = theirs.UpdatedAt
+	}
+
+	// Deleted on one side, changed on the other
+	var conflict string
+	theirsChanged, oursChanged := changedFields(base, theirs), changedFields(base, ours)
+	switch {
+	case !base.Trashed() && ours.Trashed() && !theirs.Trashed() && len(theirsChanged) > 0:
+		conflict = fmt.Sprintf("task %d (%s) was deleted on our side but changed on theirs (%s); it is kept", base.ID, merged.Description, strings.Join(theirsChanged, ", "))
+	case !base.Trashed() && theirs.Trashed() && !ours.Trashed() && len(oursChanged) > 0:
+		conflict = fmt.Sprintf("task %d (%s) was deleted on their side but changed on ours (%s); it is kept", base.ID, merged.Description, strings.Join(oursChanged, ", "))
+	}
+	if conflict != "" {
+		merged.DeletedAt = nil
+	}
+	return merged, conflict
+}
+
+// MergeFiles merges the task files base, ours and theirs (see
+// MergeTaskLists) as a git merge driver does: the merged list is written to
+// ours, which is a valid task file even if there are conflicts. Encrypted
+// files are decrypted with enc and the result is encrypted if ours was.
+func MergeFiles(basePath, oursPath, theirsPath string, enc *Encryption) (MergeResult, error) {
+	file := &JSONFileStore{Encryption: enc}
+	var lists [3]*TaskList
+	for i, path := range []string{basePath, oursPath, theirsPath} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return MergeResult{}, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			// Git passes an empty base when both sides added the file
+			lists[i] = &TaskList{NextID: 1, Tasks: []Task{}}
+			continue
+		}
+		if lists[i], err = file.decode(data); err != nil {
+			return MergeResult{}, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	result := MergeTaskLists(lists[0], lists[1], lists[2])
+	data, err := encodeTaskList(result.List)
+	if err != nil {
+		return MergeResult{}, err
+	}
+	if fileEncrypted(oursPath) {
+		if data, err = enc.seal(data); err != nil {
+			return MergeResult{}, err
+		}
+	}
+	if err := writeFileAtomic(oursPath, data, 0644); err != nil {
+		return MergeResult{}, err
+	}
+
+	return result, nil
+}
+
+// InstallMergeDriver registers the merge driver for the task file at path
+// in the git repository that contains it: it adds the file to the
+// repository's .gitattributes and sets merge.task-cli.driver in the
+// repository's git config. The driver runs the task-cli binary at exe. It
+// returns the task file's path relative to the repository root and the root.
+func InstallMergeDriver(path, exe string) (string, string, error) {
+	dir := filepath.Dir(path)
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", "", fmt.Errorf("%s is not in a git repository", path)
+	}
+	root := strings.TrimSpace(string(out))
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	rel, err := filepath.Rel(root, filepath.Join(dir, filepath.Base(path)))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", "", fmt.Errorf("%s is not in the git repository at %s", path, root)
+	}
+
+	// Add the attribute unless it is there already
+	attributes := filepath.Join(root, ".gitattributes")
+	line := "/" + filepath.ToSlash(rel) + " merge=" + mergeDriverName
+	data, err := os.ReadFile(attributes)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", err
+	}
+	if !containsLine(string(data), line) {
+		if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+			line = "\n" + line
+		}
+		if err := appendFile(attributes, []byte(line+"\n")); err != nil {
+			return "", "", err
+		}
+	}
+
+	for _, kv := range [][2]string{
+		{"merge." + mergeDriverName + ".name", "task-cli task list merge"},
+		{"merge." + mergeDriverName + ".driver", fmt.Sprintf("%q merge-driver %%O %%A %%B", filepath.ToSlash(exe))},
+	} {
+		if out, err := exec.Command("git", "-C", root, "config", kv[0], kv[1]).CombinedOutput(); err != nil {
+			return "", "", fmt.Errorf("git config %s: %v: %s", kv[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return rel, root, nil
+}
+
+// containsLine reports whether text has a line equal to line, ignoring
+// surrounding spaces.
+func containsLine(text, line string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMergeTaskLists tests merging two task lists derived from a common
+// base.
+//
+// The test includes the following cases:
+//
+//  1. Field-level merge: One side updates a task's description, the other
+//     marks it done. The test checks that both changes are kept.
+//
+//  2. Both sides change a field: The test checks that the side with the
+//     later UpdatedAt wins and that the resolution is reported.
+//
+//  3. Adds on both sides: Both sides add a different task with the same ID.
+//     The test checks that their task is renumbered after the highest ID.
+//
+//  4. Delete: One side deletes an untouched task. The test checks that it
+//     stays deleted without a conflict.
+//
+//  5. Delete vs modify: One side deletes a task the other changed. The test
+//     checks that the changed task is kept and a conflict is reported.
+func TestMergeTaskLists(t *testing.T) {
+	at := func(minutes int) time.Time {
+		return time.Date(2024, 1, 1, 9, minutes, 0, 0, time.UTC)
+	}
+	task := func(id int, description string, status Status, updated int) Task {
+		return Task{ID: id, Description: description, Status: status, CreatedAt: at(0), UpdatedAt: at(updated)}
+	}
+	deleted := at(30)
+	trashed := task(4, "Delete me", "todo", 30)
+	trashed.DeletedAt = &deleted
+	trashed5 := task(5, "Changed elsewhere", "todo", 30)
+	trashed5.DeletedAt = &deleted
+
+	base := &TaskList{NextID: 6, Tasks: []Task{
+		task(1, "Merge me", "todo", 0),
+		task(2, "Both", "todo", 0),
+		task(4, "Delete me", "todo", 0),
+		task(5, "Change me", "todo", 0),
+	}}
+	ours := &TaskList{NextID: 7, Tasks: []Task{
+		task(1, "Merge me", "done", 10),
+		task(2, "Both, ours", "todo", 10),
+		trashed,
+		trashed5,
+		task(6, "Ours added", "todo", 10),
+	}}
+	theirs := &TaskList{NextID: 7, Tasks: []Task{
+		task(1, "Merged", "todo", 5),
+		task(2, "Both, theirs", "todo", 20),
+		task(4, "Delete me", "todo", 0),
+		task(5, "Changed elsewhere", "in-progress", 20),
+		task(6, "Theirs added", "todo", 10),
+	}}
+
+	result := MergeTaskLists(base, ours, theirs)
+	merged := tasksByID(result.List)
+
+	// Case 1: Merge per field
+	if got := merged[1]; got.Description != "Merged" || got.Status != "done" || !got.UpdatedAt.Equal(at(10)) {
+		t.Errorf("Expected both changes to task 1, got %+v", got)
+	}
+
+	// Case 2: Field yang sama berubah di kedua sisi
+	if got := merged[2]; got.Description != "Both, theirs" {
+		t.Errorf("Expected the newer description of task 2, got %+v", got)
+	}
+	if len(result.Resolved) != 1 {
+		t.Errorf("Expected 1 resolved field, got %v", result.Resolved)
+	}
+
+	// Case 3: Task ditambah di kedua sisi
+	if merged[6].Description != "Ours added" || merged[7].Description != "Theirs added" {
+		t.Errorf("Expected their task to be renumbered to 7, got %+v", result.List.Tasks)
+	}
+	if len(result.Renumbered) != 1 || result.Renumbered[0].NewID != 7 || result.List.NextID != 8 {
+		t.Errorf("Expected 6 -> 7 and next ID 8, got %+v, next ID %d", result.Renumbered, result.List.NextID)
+	}
+
+	// Case 4: Task dihapus di satu sisi
+	if !merged[4].Trashed() {
+		t.Errorf("Expected task 4 to stay deleted, got %+v", merged[4])
+	}
+
+	// Case 5: Hapus vs ubah
+	if got := merged[5]; got.Trashed() || got.Status != "in-progress" {
+		t.Errorf("Expected the changed task 5 to be kept, got %+v", got)
+	}
+	if len(result.Conflicts) != 1 {
+		t.Errorf("Expected 1 conflict, got %v", result.Conflicts)
+	}
+}
+
+// TestMergeFiles tests running the merge on files as git does.
+//
+// The test includes the following cases:
+//
+//  1. Clean merge: Merge files where both sides add a task. The test checks
+//     that the merged list is written over ours.
+//
+//  2. Conflict: Merge files where a purged task was changed. The test checks
+//     that the conflict is reported and that ours still holds a valid list.
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, tasks ...Task) string {
+		path := filepath.Join(dir, name)
+		data, _ := encodeTaskList(&TaskList{Tasks: tasks})
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	now := time.Now()
+	one := Task{ID: 1, Description: "One", Status: "todo", CreatedAt: now, UpdatedAt: now}
+	two := Task{ID: 2, Description: "Two", Status: "todo", CreatedAt: now, UpdatedAt: now}
+	three := Task{ID: 2, Description: "Three", Status: "todo", CreatedAt: now, UpdatedAt: now}
+
+	// Case 1: Merge tanpa konflik
+	base := write("base.json", one)
+	ours := write("ours.json", one, two)
+	theirs := write("theirs.json", one, three)
+	if _, err := MergeFiles(base, ours, theirs, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	list, err := readTaskListFile(nil, ours)
+	if err != nil || len(list.Tasks) != 3 || list.Tasks[2].ID != 3 || list.Tasks[2].Description != "Three" {
+		t.Errorf("Expected tasks 1, 2 and 3, got %+v (%v)", list, err)
+	}
+
+	// Case 2: Konflik
+	done := one
+	done.Status = "done"
+	ours = write("ours.json")
+	theirs = write("theirs.json", done)
+	result, err := MergeFiles(base, ours, theirs, nil)
+	if err != nil || len(result.Conflicts) != 1 {
+		t.Errorf("Expected one conflict, got %v (err %v)", result.Conflicts, err)
+	}
+	list, err = readTaskListFile(nil, ours)
+	if err != nil || len(list.Tasks) != 1 || list.Tasks[0].Status != "done" {
+		t.Errorf("Expected the changed task to be kept, got %+v (%v)", list, err)
+	}
+}
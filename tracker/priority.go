@@ -0,0 +1,66 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Priority is how important a task is. Tasks from files written before
+// priorities existed have PriorityNone, which is left out of the file.
+type Priority int
+
+// The priorities, from least to most important.
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityCritical
+)
+
+// priorityNames are the names of the priorities, indexed by priority.
+var priorityNames = []string{"none", "low", "medium", "high", "critical"}
+
+// ErrUnknownPriority is returned for priorities that do not exist.
+var ErrUnknownPriority = errors.New("unknown priority")
+
+// ParsePriority returns the priority named s.
+func ParsePriority(s string) (Priority, error) {
+	for i, name := range priorityNames {
+		if name == s {
+			return Priority(i), nil
+		}
+	}
+	return PriorityNone, fmt.Errorf("%w %q (want none, low, medium, high or critical)", ErrUnknownPriority, s)
+}
+
+// valid reports whether p is one of the priorities.
+func (p Priority) valid() bool {
+	return p >= PriorityNone && p <= PriorityCritical
+}
+
+func (p Priority) String() string {
+	if !p.valid() {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// MarshalText encodes the priority for JSON by name.
+func (p Priority) MarshalText() ([]byte, error) {
+	if !p.valid() {
+		return nil, fmt.Errorf("%w %d", ErrUnknownPriority, int(p))
+	}
+	return []byte(priorityNames[p]), nil
+}
+
+// UnmarshalText decodes a priority from JSON. Unknown priorities are
+// refused.
+func (p *Priority) UnmarshalText(text []byte) error {
+	priority, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = priority
+	return nil
+}
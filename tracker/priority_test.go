@@ -0,0 +1,79 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestPriority tests setting task priorities and listing by them.
+//
+// The test includes the following cases:
+//
+//  1. Older task file: Load a file written before priorities existed. The
+//     test checks that its tasks have no priority and that saving it does
+//     not add the field.
+//
+//  2. Add and update: Add tasks with priorities and change one. The test
+//     checks that the priorities are kept and unknown ones refused.
+//
+//  3. Query: The test checks that tasks are listed most important first in
+//     file order otherwise, that SortID keeps the file order and that
+//     Priorities filters them.
+func TestPriority(t *testing.T) {
+	// Case 1: File lama tanpa prioritas
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"version": 4, "meta": {"nextId": 2}, "tasks": [
+		{"id": 1, "description": "Old", "status": "todo"}
+	]}`), 0644)
+	tr := New(NewJSONFileStore(path))
+	if task, err := tr.Get(1); err != nil || task.Priority != PriorityNone {
+		t.Fatalf("Expected no priority, got %+v (err %v)", task, err)
+	}
+	tr.Update(1, "Old task")
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "priority") {
+		t.Errorf("Expected no priority field, got %s", data)
+	}
+
+	// Case 2: Add dan update dengan prioritas
+	tr.AddTask(Task{Description: "Low", Priority: PriorityLow})
+	tr.AddTask(Task{Description: "Critical", Priority: PriorityCritical})
+	tr.AddTask(Task{Description: "Also low", Priority: PriorityLow})
+	high := PriorityHigh
+	if task, err := tr.UpdateTask(1, TaskUpdate{Priority: &high}); err != nil || task.Priority != PriorityHigh || task.Description != "Old task" {
+		t.Errorf("Expected task 1 high, got %+v (err %v)", task, err)
+	}
+	if _, err := tr.AddTask(Task{Description: "Bad", Priority: 9}); !errors.Is(err, ErrUnknownPriority) {
+		t.Errorf("Expected ErrUnknownPriority, got %v", err)
+	}
+	var task Task
+	if err := json.Unmarshal([]byte(`{"id": 1, "status": "todo", "priority": "urgent"}`), &task); !errors.Is(err, ErrUnknownPriority) {
+		t.Errorf("Expected ErrUnknownPriority decoding, got %v", err)
+	}
+
+	// Case 3: Urutan dan filter
+	ids := func(q Query) []int {
+		tasks, err := tr.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	if got := ids(Query{}); !slices.Equal(got, []int{3, 1, 2, 4}) {
+		t.Errorf("Expected 3, 1, 2, 4 by priority, got %v", got)
+	}
+	if got := ids(Query{Sort: SortID}); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Expected file order, got %v", got)
+	}
+	if got := ids(Query{Priorities: []Priority{PriorityLow}}); !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("Expected the low tasks, got %v", got)
+	}
+}
@@ -0,0 +1,362 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultProject is the name of the project kept in the resolved task file
+// itself. It always exists.
+const DefaultProject = "default"
+
+// projectNamePattern is what project names may look like.
+var projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Projects manages the named task lists that live next to a task file.
+// The task file itself is the default project; every other project is a
+// directory holding its own task file in the projects directory, e.g.
+// tasks.projects/work/tasks.json for tasks.json.
+type Projects struct {
+	// DefaultPath is the task file of the default project.
+	DefaultPath string
+}
+
+// Dir returns the directory holding the projects other than the default.
+func (p Projects) Dir() string {
+	ext := filepath.Ext(p.DefaultPath)
+	return strings.TrimSuffix(p.DefaultPath, ext) + ".projects"
+}
+
+// currentPath is the file recording the project selected with Use.
+func (p Projects) currentPath() string {
+	return filepath.Join(p.Dir(), ".current")
+}
+
+// Path returns the task file of the named project. Invalid names, which
+// could point outside the projects directory, are refused.
+func (p Projects) Path(name string) (string, error) {
+	if name == DefaultProject {
+		return p.DefaultPath, nil
+	}
+	if err := checkProjectName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(p.Dir(), name, filepath.Base(p.DefaultPath)), nil
+}
+
+// Exists reports whether the named project exists. Invalid names never
+// do.
+func (p Projects) Exists(name string) bool {
+	if name == DefaultProject {
+		return true
+	}
+	if checkProjectName(name) != nil {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(p.Dir(), name))
+	return err == nil && info.IsDir()
+}
+
+// List returns the names of all projects, the default project first.
+func (p Projects) List() ([]string, error) {
+	names := []string{DefaultProject}
+	entries, err := os.ReadDir(p.Dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, err
+	}
+	var others []string
+	for _, entry := range entries {
+		if entry.IsDir() && projectNamePattern.MatchString(entry.Name()) {
+			others = append(others, entry.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...), nil
+}
+
+// Current returns the project selected with Use, or the default project.
+// A selected project that no longer exists falls back to the default.
+func (p Projects) Current() string {
+	data, err := os.ReadFile(p.currentPath())
+	if err != nil {
+		return DefaultProject
+	}
+	name := strings.TrimSpace(string(data))
+	if !p.Exists(name) {
+		return DefaultProject
+	}
+	return name
+}
+
+// Use makes name the current project.
+func (p Projects) Use(name string) error {
+	if err := checkProjectName(name); err != nil {
+		return err
+	}
+	if !p.Exists(name) {
+		return fmt.Errorf("project %q not found", name)
+	}
+	if err := os.MkdirAll(p.Dir(), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p.currentPath(), []byte(name+"\n"), 0644)
+}
+
+// checkProjectName checks that name is a valid project name, which keeps
+// it inside the projects directory.
+func checkProjectName(name string) error {
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// validateProjectName checks that name can be used for a new project.
+func validateProjectName(name string) error {
+	if err := checkProjectName(name); err != nil {
+		return err
+	}
+	if name == DefaultProject {
+		return fmt.Errorf("project name %q is reserved", name)
+	}
+	return nil
+}
+
+// Add creates an empty project.
+func (p Projects) Add(name string) error {
+	if err := validateProjectName(name); err != nil {
+		return err
+	}
+	if p.Exists(name) {
+		return fmt.Errorf("project %q already exists", name)
+	}
+	return os.MkdirAll(filepath.Join(p.Dir(), name), 0755)
+}
+
+// Rename renames a project, keeping its tasks, history and backups.
+func (p Projects) Rename(oldName, newName string) error {
+	if oldName == DefaultProject {
+		return errors.New("the default project cannot be renamed")
+	}
+	if !p.Exists(oldName) {
+		return fmt.Errorf("project %q not found", oldName)
+	}
+	if err := validateProjectName(newName); err != nil {
+		return err
+	}
+	if p.Exists(newName) {
+		return fmt.Errorf("project %q already exists", newName)
+	}
+
+	current := p.Current()
+	if err := os.Rename(filepath.Join(p.Dir(), oldName), filepath.Join(p.Dir(), newName)); err != nil {
+		return err
+	}
+	if current == oldName {
+		return p.Use(newName)
+	}
+	return nil
+}
+
+// Remove deletes a project with all its tasks.
+func (p Projects) Remove(name string) error {
+	if name == DefaultProject {
+		return errors.New("the default project cannot be removed")
+	}
+	if err := checkProjectName(name); err != nil {
+		return err
+	}
+	if !p.Exists(name) {
+		return fmt.Errorf("project %q not found", name)
+	}
+	return os.RemoveAll(filepath.Join(p.Dir(), name))
+}
+
+// ProjectInfo describes a project for listings.
+type ProjectInfo struct {
+	Name string
+	// Tasks is the number of tasks outside the trash.
+	Tasks int
+	// Current reports whether the project is the one selected with Use.
+	Current bool
+}
+
+// ListProjects returns every project with its number of tasks, the default
+// project first.
+func ListProjects(projects Projects, cfg Config) ([]ProjectInfo, error) {
+	names, err := projects.List()
+	if err != nil {
+		return nil, err
+	}
+	current := projects.Current()
+	infos := make([]ProjectInfo, 0, len(names))
+	for _, name := range names {
+		path, err := projects.Path(name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := OpenStore(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		list, err := s.Load()
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", name, err)
+		}
+		infos = append(infos, ProjectInfo{Name: name, Tasks: len(list.Live()), Current: name == current})
+	}
+	return infos, nil
+}
+
+// RemoveProject deletes a project. A project that still has tasks is only
+// removed with force.
+func RemoveProject(projects Projects, cfg Config, name string, force bool) error {
+	if projects.Exists(name) && name != DefaultProject && !force {
+		path, err := projects.Path(name)
+		if err != nil {
+			return err
+		}
+		s, err := OpenStore(path, cfg)
+		if err != nil {
+			return err
+		}
+		list, err := s.Load()
+		if err != nil {
+			return err
+		}
+		if n := len(list.Live()); n > 0 {
+			return fmt.Errorf("project %q still has %d tasks; use --force to remove it anyway", name, n)
+		}
+	}
+	return projects.Remove(name)
+}
+
+// MoveTask moves the task with the given ID from the tracker's store to the
+// target store and returns it as it is stored there. The task keeps its
+// status and timestamps but gets the next free ID in the target project.
+// Both stores stay locked while the task is moved, so the task cannot
+// change in between; the locks are taken in the order of the task file
+// paths, so that moves in opposite directions do not wait for each other.
+// If the store saved second fails, the change to the other is undone.
+// Tasks with subtasks cannot be moved, and moved subtasks become top-level
+// tasks.
+func (t *Tracker) MoveTask(id int, target TaskStore, targetName string) (Task, error) {
+	if id <= 0 {
+		return Task{}, ErrInvalidID
+	}
+	if err := checkProjectName(targetName); err != nil {
+		return Task{}, err
+	}
+
+	var original, moved Task
+	move := func(source, dest *TaskList) error {
+		i := source.LiveIndex(id)
+		if i < 0 {
+			return ErrTaskNotFound
+		}
+		original = source.Tasks[i]
+		if children := subtasks(source, id, false); len(children) > 0 {
+			return fmt.Errorf("%w (%d); move or detach them first", ErrHasSubtasks, len(children))
+		}
+		task := original
+		task.ParentID = 0
+		moved = dest.Add(task)
+		source.Remove(id)
+		return nil
+	}
+
+	sourceFirst := lockPath(t.Store) <= lockPath(target)
+	outer, inner := t.Store, target
+	if !sourceFirst {
+		outer, inner = target, t.Store
+	}
+	innerSaved := false
+	err := outer.Modify(func(outerList *TaskList) error {
+		err := inner.Modify(func(innerList *TaskList) error {
+			if sourceFirst {
+				return move(outerList, innerList)
+			}
+			return move(innerList, outerList)
+		})
+		innerSaved = err == nil
+		return err
+	})
+	if err == nil {
+		return moved, nil
+	}
+	if !innerSaved {
+		return Task{}, err
+	}
+
+	// Only the inner store was saved; undo the change to it
+	if sourceFirst {
+		undo := target.Modify(func(list *TaskList) error {
+			if i, ok := uidIndex(list)[moved.UID]; ok {
+				list.Tasks = append(list.Tasks[:i], list.Tasks[i+1:]...)
+			}
+			return nil
+		})
+		if undo != nil {
+			return moved, fmt.Errorf("task copied to project %s as ID %d but not removed here: %w", targetName, moved.ID, err)
+		}
+		return Task{}, err
+	}
+	undo := t.Store.Modify(func(list *TaskList) error {
+		list.Put(original)
+		return nil
+	})
+	if undo != nil {
+		return Task{}, fmt.Errorf("task %d (%q) removed here but not added to project %s: %w", id, original.Description, targetName, err)
+	}
+	return Task{}, err
+}
+
+// ProjectTask is a task together with the project it belongs to.
+type ProjectTask struct {
+	Project string
+	Task
+}
+
+// ListAllProjects returns the tasks q matches in every project, in its
+// order. Tasks in the trash are left out.
+func ListAllProjects(projects Projects, cfg Config, q Query) ([]ProjectTask, error) {
+	if len(q.Workflow) == 0 {
+		q.Workflow = cfg.Statuses
+	}
+	names, err := projects.List()
+	if err != nil {
+		return nil, err
+	}
+	var tasks []ProjectTask
+	for _, name := range names {
+		path, err := projects.Path(name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := OpenStore(path, cfg)
+		if err != nil {
+			return nil, err
+		}
+		list, err := s.Load()
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", name, err)
+		}
+		for _, task := range list.Live() {
+			if q.Match(task) {
+				tasks = append(tasks, ProjectTask{Project: name, Task: task})
+			}
+		}
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return q.less(tasks[i].Task, tasks[j].Task)
+	})
+	return tasks, nil
+}
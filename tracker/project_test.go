@@ -0,0 +1,262 @@
+package tracker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestProjects tests managing named projects.
+//
+// The test includes the following cases:
+//
+//  1. Add: Add a project. The test checks that it is listed after the default
+//     project and that adding it again or adding an invalid name fails.
+//
+//  2. Use: Select the project. The test checks that it becomes the current
+//     project and that an unknown project cannot be selected.
+//
+//  3. Rename: Rename the project. The test checks that the current project
+//     follows the rename and that its tasks are kept.
+//
+//  4. Remove: Remove the project. The test checks that a project with tasks
+//     is only removed with force and that the default project is current
+//     again afterwards.
+//
+//  5. Traversal: The test checks that names such as ".." are refused by
+//     Path, Use, Remove and MoveTask, never exist, are ignored as the
+//     current project and leave the directories outside the projects
+//     directory alone.
+func TestProjects(t *testing.T) {
+	projects := Projects{DefaultPath: filepath.Join(t.TempDir(), "tasks.json")}
+	cfg := Config{}
+
+	// Case 1: Tambah project
+	if err := projects.Add("work"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	names, err := projects.List()
+	if err != nil || len(names) != 2 || names[0] != DefaultProject || names[1] != "work" {
+		t.Errorf("Expected [default work], got %v (%v)", names, err)
+	}
+	if err := projects.Add("work"); err == nil {
+		t.Error("Expected an error when adding an existing project")
+	}
+	for _, name := range []string{"", "default", "../x", ".hidden"} {
+		if err := projects.Add(name); err == nil {
+			t.Errorf("Expected an error for project name %q", name)
+		}
+	}
+
+	// Case 2: Pilih project
+	if err := projects.Use("work"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if current := projects.Current(); current != "work" {
+		t.Errorf("Expected current project work, got %s", current)
+	}
+	if err := projects.Use("nope"); err == nil {
+		t.Error("Expected an error when using an unknown project")
+	}
+
+	// Case 3: Ganti nama project
+	workPath, _ := projects.Path("work")
+	work, err := OpenStore(workPath, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tr := New(work)
+	tr.Add("Work task")
+	if err := projects.Rename("work", "job"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if current := projects.Current(); current != "job" {
+		t.Errorf("Expected current project job, got %s", current)
+	}
+	jobPath, _ := projects.Path("job")
+	job, _ := OpenStore(jobPath, cfg)
+	list, err := job.Load()
+	if err != nil || len(list.Live()) != 1 || list.Live()[0].Description != "Work task" {
+		t.Errorf("Expected the task to be kept after the rename, got %+v (%v)", list, err)
+	}
+
+	// Case 4: Hapus project
+	if err := RemoveProject(projects, cfg, "job", false); err == nil {
+		t.Error("Expected an error when removing a project with tasks")
+	}
+	if err := RemoveProject(projects, cfg, "job", true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if projects.Exists("job") {
+		t.Error("Expected project job to be removed")
+	}
+	if current := projects.Current(); current != DefaultProject {
+		t.Errorf("Expected current project default, got %s", current)
+	}
+
+	// Case 5: Nama project yang keluar dari direktori project
+	outside := filepath.Join(filepath.Dir(projects.DefaultPath), "outside")
+	os.MkdirAll(filepath.Join(outside, "keep"), 0755)
+	os.WriteFile(projects.currentPath(), []byte("../outside\n"), 0644)
+	if current := projects.Current(); current != DefaultProject {
+		t.Errorf("Expected current project default, got %s", current)
+	}
+	for _, name := range []string{"..", "../outside", "x/../../outside"} {
+		if _, err := projects.Path(name); err == nil {
+			t.Errorf("Expected Path to refuse %q", name)
+		}
+		if projects.Exists(name) {
+			t.Errorf("Expected %q not to exist", name)
+		}
+		if err := projects.Use(name); err == nil {
+			t.Errorf("Expected Use to refuse %q", name)
+		}
+		if err := RemoveProject(projects, cfg, name, true); err == nil {
+			t.Errorf("Expected Remove to refuse %q", name)
+		}
+		if _, err := New(NewMemoryStore()).MoveTask(1, NewMemoryStore(), name); err == nil {
+			t.Errorf("Expected MoveTask to refuse %q", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "keep")); err != nil {
+		t.Errorf("Expected the outside directory to be kept, got %v", err)
+	}
+}
+
+// TestMoveTask tests moving a task between projects.
+//
+// The test includes the following cases:
+//
+//  1. Move: Move a task to another project. The test checks that it is gone
+//     from the source and added to the target with the next free ID, keeping
+//     its status and timestamps.
+//
+//  2. Unknown task: Move a task that does not exist. The test checks that
+//     'task ID not found' is returned and that the target is unchanged.
+//
+//  3. Failed save: Move a task out of a store that cannot be saved. The
+//     test checks that the task stays in the source and that the copy is
+//     taken back out of the target.
+//
+//  4. Lock order: Move a task to a project whose task file sorts first. The
+//     test checks that the target is locked first.
+//
+//  5. Failed target: Move a task to a store that cannot be saved and is
+//     locked first. The test checks that the task is put back in the source
+//     with its ID.
+func TestMoveTask(t *testing.T) {
+	dir := t.TempDir()
+	source := NewJSONFileStore(filepath.Join(dir, "source.json"))
+	target := NewJSONFileStore(filepath.Join(dir, "target.json"))
+	tr := New(target)
+	tr.Add("Already there")
+	tr.Store = source
+	tr.Add("Move me")
+	tr.Mark(1, "done")
+	before, _ := source.Get(1)
+
+	// Case 1: Pindahkan task ke project lain
+	if _, err := tr.MoveTask(1, target, "target"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if tasks, _ := tr.Tasks(); len(tasks) != 0 {
+		t.Errorf("Expected the source to be empty, got %+v", tasks)
+	}
+	moved, err := target.Get(2)
+	if err != nil {
+		t.Fatalf("Expected task 2 in the target, got %v", err)
+	}
+	if moved.Description != "Move me" || moved.Status != "done" ||
+		!moved.CreatedAt.Equal(before.CreatedAt) || !moved.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Errorf("Expected %+v to keep the fields of %+v", moved, before)
+	}
+
+	// Case 2: Task tidak ditemukan
+	if _, err := tr.MoveTask(1, target, "target"); err == nil || err.Error() != "task ID not found" {
+		t.Errorf("Expected 'task ID not found' error, got %v", err)
+	}
+	list, _ := target.Load()
+	if len(list.Tasks) != 2 {
+		t.Errorf("Expected the target to keep 2 tasks, got %+v", list.Tasks)
+	}
+
+	// Case 3: Sumber gagal disimpan
+	tr.Store = NewMemoryStore()
+	tr.Add("Stay here")
+	tr.Store = failingStore{tr.Store}
+	if _, err := tr.MoveTask(1, target, "target"); !errors.Is(err, errSaveFailed) {
+		t.Errorf("Expected the save error, got %v", err)
+	}
+	if tasks, _ := tr.Tasks(); len(tasks) != 1 {
+		t.Errorf("Expected the task to stay in the source, got %+v", tasks)
+	}
+	if list, _ := target.Load(); len(list.Tasks) != 2 {
+		t.Errorf("Expected the copy to be taken out of the target, got %+v", list.Tasks)
+	}
+
+	// Case 4: Urutan lock
+	var locked []string
+	tr.Store = recordingStore{source, "source", &locked}
+	tr.Add("Archive me")
+	archive := NewJSONFileStore(filepath.Join(dir, "archive.json"))
+	locked = nil
+	if _, err := tr.MoveTask(2, recordingStore{archive, "archive", &locked}, "archive"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(locked, ",") != "archive,source" {
+		t.Errorf("Expected the archive to be locked first, got %v", locked)
+	}
+
+	// Case 5: Target gagal disimpan
+	tr.Store = source
+	tr.Add("Come back")
+	if _, err := tr.MoveTask(3, failingStore{archive}, "archive"); !errors.Is(err, errSaveFailed) {
+		t.Errorf("Expected the save error, got %v", err)
+	}
+	if task, err := source.Get(3); err != nil || task.Description != "Come back" {
+		t.Errorf("Expected the task back in the source, got %+v (err %v)", task, err)
+	}
+	if list, _ := archive.Load(); len(list.Tasks) != 1 {
+		t.Errorf("Expected no copy in the archive, got %+v", list.Tasks)
+	}
+}
+
+// recordingStore is a TaskStore that adds its name to log whenever it is
+// modified, which is when it takes its lock.
+type recordingStore struct {
+	TaskStore
+	name string
+	log  *[]string
+}
+
+func (s recordingStore) Modify(fn func(list *TaskList) error) error {
+	*s.log = append(*s.log, s.name)
+	return s.TaskStore.Modify(fn)
+}
+
+func (s recordingStore) Unwrap() TaskStore {
+	return s.TaskStore
+}
+
+// errSaveFailed is returned by failingStore.
+var errSaveFailed = errors.New("save failed")
+
+// failingStore is a TaskStore whose changes run but are never saved.
+type failingStore struct {
+	TaskStore
+}
+
+func (s failingStore) Modify(fn func(list *TaskList) error) error {
+	return s.TaskStore.Modify(func(list *TaskList) error {
+		if err := fn(list); err != nil {
+			return err
+		}
+		return errSaveFailed
+	})
+}
+
+func (s failingStore) Unwrap() TaskStore {
+	return s.TaskStore
+}
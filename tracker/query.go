@@ -0,0 +1,152 @@
+package tracker
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// SortKey orders the tasks a Query returns.
+type SortKey string
+
+// The orders a Query can return tasks in.
+const (
+	// SortPriority puts the most important tasks first and keeps the file
+	// order among tasks of the same priority.
+	SortPriority SortKey = "priority"
+	// SortID keeps the file order.
+	SortID SortKey = "id"
+	// SortDue puts the tasks due first first and tasks without a due date
+	// last.
+	SortDue SortKey = "due"
+)
+
+// ParseSortKey returns the sort key named s.
+func ParseSortKey(s string) (SortKey, error) {
+	switch key := SortKey(s); key {
+	case SortPriority, SortID, SortDue:
+		return key, nil
+	}
+	return "", fmt.Errorf("unknown sort key %q (want priority, id or due)", s)
+}
+
+// Query selects tasks that are not in the trash and orders them.
+type Query struct {
+	// Statuses are the statuses to include; all if none.
+	Statuses []Status
+	// Priorities are the priorities to include; all if none.
+	Priorities []Priority
+	// Tags are groups of tags: a task must have at least one tag of every
+	// group, so tags in a group are alternatives and groups all apply.
+	Tags [][]string
+	// WithoutTags are tags a task must not have.
+	WithoutTags []string
+	// Overdue limits the tasks to the overdue ones; see Task.Overdue.
+	Overdue bool
+	// DueBefore, if set, limits the tasks to those due before it.
+	DueBefore *time.Time
+	// Sort orders the tasks; SortPriority if empty.
+	Sort SortKey
+
+	// Now is the time Overdue is judged against and Workflow tells which
+	// statuses are closed. Tracker.Query sets them from the tracker if they
+	// are not set; otherwise they default to time.Now and DefaultWorkflow.
+	Now      time.Time
+	Workflow Workflow
+}
+
+// Match reports whether task passes the filters of q.
+func (q Query) Match(task Task) bool {
+	if !hasStatus(task, q.Statuses) || !hasPriority(task, q.Priorities) {
+		return false
+	}
+	for _, group := range q.Tags {
+		if !slices.ContainsFunc(group, task.HasTag) {
+			return false
+		}
+	}
+	if slices.ContainsFunc(q.WithoutTags, task.HasTag) {
+		return false
+	}
+	if q.Overdue && !task.Overdue(q.now(), q.workflow()) {
+		return false
+	}
+	if q.DueBefore != nil && (task.DueAt == nil || !task.DueAt.Before(*q.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// hasPriority reports whether task has one of priorities, or if priorities
+// is empty.
+func hasPriority(task Task, priorities []Priority) bool {
+	if len(priorities) == 0 {
+		return true
+	}
+	for _, priority := range priorities {
+		if task.Priority == priority {
+			return true
+		}
+	}
+	return false
+}
+
+// now returns the time of q, time.Now if it has none.
+func (q Query) now() time.Time {
+	if q.Now.IsZero() {
+		return time.Now()
+	}
+	return q.Now
+}
+
+// workflow returns the workflow of q, DefaultWorkflow if it has none.
+func (q Query) workflow() Workflow {
+	if len(q.Workflow) == 0 {
+		return DefaultWorkflow
+	}
+	return q.Workflow
+}
+
+// less reports whether a comes before b in the order of q. Tasks it does
+// not order keep their relative order.
+func (q Query) less(a, b Task) bool {
+	switch q.Sort {
+	case SortID:
+		return false
+	case SortDue:
+		return a.DueAt != nil && (b.DueAt == nil || a.DueAt.Before(*b.DueAt))
+	default:
+		return a.Priority > b.Priority
+	}
+}
+
+// Apply returns the tasks q matches, in its order. tasks is reused.
+func (q Query) Apply(tasks []Task) []Task {
+	filtered := tasks[:0]
+	for _, task := range tasks {
+		if q.Match(task) {
+			filtered = append(filtered, task)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return q.less(filtered[i], filtered[j])
+	})
+	return filtered
+}
+
+// Query returns the tasks that are not in the trash and match q, in its
+// order.
+func (t *Tracker) Query(q Query) ([]Task, error) {
+	if q.Now.IsZero() {
+		q.Now = t.Now()
+	}
+	if len(q.Workflow) == 0 {
+		q.Workflow = t.workflow()
+	}
+	tasks, err := t.Tasks()
+	if err != nil {
+		return nil, err
+	}
+	return q.Apply(tasks), nil
+}
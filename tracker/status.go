@@ -0,0 +1,95 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is the state of a task in its workflow. Which statuses exist and
+// how tasks move between them is up to the Workflow. Task files keep
+// whatever status they hold, so that doctor can report and repair
+// hand-edited ones; statuses from users and the config go through
+// ParseStatus.
+type Status string
+
+// The statuses of DefaultWorkflow.
+const (
+	StatusTodo       Status = "todo"
+	StatusInProgress Status = "in-progress"
+	StatusDone       Status = "done"
+)
+
+// Errors for statuses and status changes, for use with errors.Is.
+var (
+	// ErrUnknownStatus is returned for statuses that are malformed or not
+	// part of the workflow.
+	ErrUnknownStatus = errors.New("unknown status")
+	// ErrIllegalTransition is returned when a task cannot move from its
+	// status to the requested one; see TransitionError.
+	ErrIllegalTransition = errors.New("illegal status transition")
+)
+
+// ParseStatus returns the status named s, which must be a well-formed
+// status name: lower case letters, digits and dashes, starting with a
+// letter. Whether the workflow has it is checked by Workflow.Parse.
+func ParseStatus(s string) (Status, error) {
+	if !validStatusName(s) {
+		return "", fmt.Errorf("%w %q (want lower case letters, digits and dashes)", ErrUnknownStatus, s)
+	}
+	return Status(s), nil
+}
+
+// validStatusName reports whether s is a well-formed status name.
+func validStatusName(s string) bool {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalText encodes the status for JSON as it is.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText decodes a status from JSON as it is written. Malformed
+// statuses are left to Doctor and Mark, which report and replace them.
+func (s *Status) UnmarshalText(text []byte) error {
+	*s = Status(text)
+	return nil
+}
+
+// TransitionError is returned when a command tries to move a task to a
+// status its current status does not lead to.
+type TransitionError struct {
+	ID       int
+	From, To Status
+	// Initial is the first status of the workflow, which only Reopen moves
+	// tasks back to.
+	Initial Status
+	// Reopenable reports whether the task could be reopened instead.
+	Reopenable bool
+}
+
+func (e *TransitionError) Error() string {
+	if e.From == e.To {
+		return fmt.Sprintf("task %d is already %s", e.ID, e.From)
+	}
+	if e.To == e.Initial && e.Reopenable {
+		return fmt.Sprintf("task %d is %s; reopen it to move it back to %s", e.ID, e.From, e.To)
+	}
+	if e.Reopenable {
+		return fmt.Sprintf("task %d is %s and cannot be marked as %s; reopen it first", e.ID, e.From, e.To)
+	}
+	return fmt.Sprintf("task %d is %s and cannot be marked as %s", e.ID, e.From, e.To)
+}
+
+// Is makes a TransitionError match ErrIllegalTransition.
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
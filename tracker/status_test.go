@@ -0,0 +1,123 @@
+package tracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestStatusJSON tests encoding and decoding statuses.
+//
+// The test includes the following cases:
+//
+//  1. Known status: Round-trip a task through JSON. The test checks that the
+//     status survives.
+//
+//  2. Malformed status: Decode and encode a task with a status that is not
+//     a well-formed name. The test checks that it is kept as written both
+//     ways, for doctor to repair, and that Mark refuses it with
+//     ErrUnknownStatus.
+//
+//  3. Older task file: Load a version 3 file with misspelled and unknown
+//     statuses. The test checks that they are turned into known statuses.
+func TestStatusJSON(t *testing.T) {
+	// Case 1: Status yang dikenal
+	data, err := json.Marshal(Task{ID: 1, Description: "Task", Status: StatusInProgress})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil || task.Status != StatusInProgress {
+		t.Errorf("Expected status in-progress, got %q (err %v)", task.Status, err)
+	}
+
+	// Case 2: Nama status yang tidak valid
+	err = json.Unmarshal([]byte(`{"id": 1, "description": "Task", "status": "In Progress"}`), &task)
+	if err != nil || task.Status != "In Progress" {
+		t.Errorf("Expected the status as written, got %q (err %v)", task.Status, err)
+	}
+	if data, err := json.Marshal(task); err != nil || !strings.Contains(string(data), `"In Progress"`) {
+		t.Errorf("Expected the status as written, got %s (err %v)", data, err)
+	}
+	tr := New(NewMemoryStore())
+	tr.Add("Task")
+	if _, err := tr.Mark(1, "In Progress"); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus marking, got %v", err)
+	}
+
+	// Case 3: File versi lama dengan status bebas
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`{"version": 3, "meta": {"nextId": 4}, "tasks": [
+		{"id": 1, "description": "One", "status": "Completed"},
+		{"id": 2, "description": "Two", "status": "blocked"},
+		{"id": 3, "description": "Three", "status": "in-progress"}
+	]}`), 0644)
+	list, err := NewJSONFileStore(path).Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []Status{StatusDone, StatusTodo, StatusInProgress}
+	for i, task := range list.Tasks {
+		if task.Status != want[i] {
+			t.Errorf("Expected task %d to be %s, got %q", task.ID, want[i], task.Status)
+		}
+	}
+}
+
+// TestStatusTransitions tests the status changes Mark and Reopen allow.
+//
+// The test includes the following cases:
+//
+//  1. Forward: Mark a task as in progress and then as done. The test checks
+//     that both changes are made.
+//
+//  2. Illegal: Mark the done task as in progress and as todo. The test checks
+//     that a *TransitionError matching ErrIllegalTransition is returned and
+//     the task is unchanged.
+//
+//  3. Reopen: Reopen the done task, then reopen it again. The test checks that
+//     it is todo and that reopening a todo task is refused.
+//
+//  4. Unknown status: Mark a task with a status that does not exist. The test
+//     checks that ErrUnknownStatus is returned.
+func TestStatusTransitions(t *testing.T) {
+	mem := NewMemoryStore()
+	tr := New(mem)
+	tr.Add("Task")
+
+	// Case 1: Perubahan ke depan
+	if _, err := tr.Mark(1, StatusInProgress); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if task, err := tr.Mark(1, StatusDone); err != nil || task.Status != StatusDone {
+		t.Fatalf("Expected the task done, got %+v (err %v)", task, err)
+	}
+
+	// Case 2: Perubahan yang tidak diizinkan
+	for _, status := range []Status{StatusInProgress, StatusTodo} {
+		_, err := tr.Mark(1, status)
+		var terr *TransitionError
+		if !errors.Is(err, ErrIllegalTransition) || !errors.As(err, &terr) || terr.From != StatusDone || terr.To != status {
+			t.Errorf("Expected an illegal transition to %s, got %v", status, err)
+		}
+	}
+	if task, _ := mem.Get(1); task.Status != StatusDone {
+		t.Errorf("Expected the task still done, got %q", task.Status)
+	}
+
+	// Case 3: Reopen
+	if task, err := tr.Reopen(1); err != nil || task.Status != StatusTodo {
+		t.Fatalf("Expected the task todo, got %+v (err %v)", task, err)
+	}
+	if _, err := tr.Reopen(1); !errors.Is(err, ErrIllegalTransition) {
+		t.Errorf("Expected reopening a todo task to fail, got %v", err)
+	}
+
+	// Case 4: Status tidak dikenal
+	if _, err := tr.Mark(1, "blocked"); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus, got %v", err)
+	}
+}
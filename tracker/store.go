@@ -0,0 +1,488 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TaskStore is the storage backend the task commands operate on.
+type TaskStore interface {
+	// Load returns the stored task list.
+	Load() (*TaskList, error)
+	// Save replaces the stored task list.
+	Save(list *TaskList) error
+	// Get returns the task with the given ID.
+	Get(id int) (Task, error)
+	// Modify loads the task list, hands it to fn and saves it afterwards.
+	// Nothing is saved when fn returns an error.
+	Modify(fn func(list *TaskList) error) error
+}
+
+// Notices receives the notes the stores write when they change a task file
+// on their own while loading it: upgrading it from an older format version
+// or renumbering duplicate task IDs. Set it to io.Discard to drop them.
+var Notices io.Writer = os.Stderr
+
+// getTask looks up the task with the given ID in list.
+func getTask(list *TaskList, id int) (Task, error) {
+	i := list.Index(id)
+	if i < 0 {
+		return Task{}, ErrTaskNotFound
+	}
+	return list.Tasks[i], nil
+}
+
+// JSONFileStore keeps the task list as JSON in a single file, in the format
+// described by formatVersion.
+//
+// Writes go through writeFileAtomic and the previous contents are kept in
+// a ".bak" file next to it, so a damaged file can be rolled back with
+// RestoreBackup. Save, Modify and RestoreBackup hold an exclusive lock on a
+// ".lock" file next to the task file, so concurrent task-cli processes do
+// not lose each other's updates.
+//
+// An encrypted task file (see EncryptTaskFile) is decrypted with
+// Encryption when it is read and stays encrypted when it is saved.
+type JSONFileStore struct {
+	Path string
+	// LockTimeout is how long to wait for the lock held by another
+	// process. Zero means DefaultLockTimeout.
+	LockTimeout time.Duration
+	// Backups configures the rolling backups taken before every save.
+	// They are off by default.
+	Backups BackupPolicy
+	// Encryption opens and seals an encrypted task file. Without it
+	// encrypted files cannot be read.
+	Encryption *Encryption
+
+	// cache holds the list last read, for as long as the file is the same,
+	// and the JSON of its tasks, which save copies for the tasks that did
+	// not change.
+	mu    sync.Mutex
+	cache fileCache
+}
+
+// fileCache is what JSONFileStore keeps of the task file it read last.
+type fileCache struct {
+	info    os.FileInfo
+	list    *TaskList
+	encoded map[int]encodedTask
+}
+
+// cacheMinAge is how old a task file must be to be cached. Edits made in
+// place may leave the size and, within the timestamp granularity of the
+// file system, the modification time as they were; a file modified well
+// before it was read cannot have been changed since without a later
+// modification time.
+const cacheMinAge = 2 * time.Second
+
+// matches reports whether the file described by info is still the one
+// cached. Saves replace the file, so a saved file is never the same file.
+func (c fileCache) matches(info os.FileInfo) bool {
+	return c.list != nil && os.SameFile(c.info, info) && c.info.ModTime().Equal(info.ModTime()) && c.info.Size() == info.Size()
+}
+
+// DefaultLockTimeout is the lock wait used when JSONFileStore.LockTimeout
+// is zero.
+const DefaultLockTimeout = 5 * time.Second
+
+// NewJSONFileStore returns a store backed by the JSON file at path.
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{Path: path}
+}
+
+// CorruptFileError is returned by JSONFileStore.Load when the task file
+// cannot be parsed. Backup names the last good copy, if there is one.
+type CorruptFileError struct {
+	Path   string
+	Backup string
+	Err    error
+}
+
+func (e *CorruptFileError) Error() string {
+	return fmt.Sprintf("%s is damaged: %v", e.Path, e.Err)
+}
+
+func (e *CorruptFileError) Unwrap() error {
+	return e.Err
+}
+
+// backupPath is where the last good copy of the task file is kept.
+func (s *JSONFileStore) backupPath() string {
+	return s.Path + ".bak"
+}
+
+// corruptPath is where RestoreBackup keeps the damaged task file.
+func (s *JSONFileStore) corruptPath() string {
+	return s.Path + ".corrupt"
+}
+
+// Load reads the task list from the file. A missing file is an empty list.
+//
+// A temporary file left behind by an interrupted write does not affect the
+// task file, because writes are atomic; it is cleaned up by the next save.
+// If the task file cannot be parsed a *CorruptFileError is returned, and if
+// it was written by a newer task-cli a *FileVersionError.
+//
+// Files in an older format are upgraded and saved once, after a copy of the
+// original has been written next to them. Files written by older versions
+// may also contain duplicate IDs; these are renumbered once and the new IDs
+// are reported on stderr.
+func (s *JSONFileStore) Load() (*TaskList, error) {
+	list, from, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if from == formatVersion && !list.hasDuplicateIDs() {
+		return list, nil
+	}
+
+	// Upgrade and repair the file under the lock
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.readRepaired()
+}
+
+// read decodes the task file without repairing it. It also returns the
+// format version the file was in.
+func (s *JSONFileStore) read() (*TaskList, int, error) {
+	// Commands load the file more than once; decoding a large file again
+	// takes far longer than checking it is the same
+	info, err := os.Stat(s.Path)
+	if err == nil {
+		s.mu.Lock()
+		cache := s.cache
+		s.mu.Unlock()
+		if cache.matches(info) {
+			return cache.list.Clone(), formatVersion, nil
+		}
+	}
+
+	file, err := os.ReadFile(s.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Return an empty list if file doesn't exist
+			return &TaskList{NextID: 1, Tasks: []Task{}}, formatVersion, nil
+		}
+		return nil, 0, err
+	}
+
+	if isEncrypted(file) {
+		// A wrong passphrase is not damage, so it is not a CorruptFileError
+		if file, err = s.Encryption.open(file); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", s.Path, err)
+		}
+	}
+
+	list, from, encoded, err := decodeTaskFile(file)
+	var verr *FileVersionError
+	if errors.As(err, &verr) {
+		return nil, from, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	if err != nil {
+		cerr := &CorruptFileError{Path: s.Path, Err: err}
+		if backup, err := os.ReadFile(s.backupPath()); err == nil {
+			if _, err := s.decode(backup); err == nil {
+				cerr.Backup = s.backupPath()
+			}
+		}
+		return nil, from, cerr
+	}
+
+	cache := fileCache{encoded: encoded}
+	if from == formatVersion && info != nil && time.Since(info.ModTime()) > cacheMinAge {
+		cache.info, cache.list = info, list.Clone()
+	}
+	s.mu.Lock()
+	s.cache = cache
+	s.mu.Unlock()
+	return list, from, nil
+}
+
+// decode decrypts data if needed and decodes it as a task file.
+func (s *JSONFileStore) decode(data []byte) (*TaskList, error) {
+	data, err := s.Encryption.openIfEncrypted(data)
+	if err != nil {
+		return nil, err
+	}
+	list, _, err := decodeTaskList(data)
+	return list, err
+}
+
+// readRepaired reads the task file, upgrading it to the current format and
+// renumbering duplicate IDs. An upgraded or repaired file is saved right
+// away. The caller must hold the lock.
+func (s *JSONFileStore) readRepaired() (*TaskList, error) {
+	list, from, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	changes := list.repairDuplicateIDs()
+	if from == formatVersion && len(changes) == 0 {
+		return list, nil
+	}
+
+	if from < formatVersion {
+		if err := s.backupBeforeMigration(from); err != nil {
+			return nil, err
+		}
+	}
+	reportIDChanges(changes)
+	return list, s.save(list)
+}
+
+// migrationBackupPath is where the original of a task file in format
+// version from is kept when it is upgraded.
+func (s *JSONFileStore) migrationBackupPath(from int) string {
+	return fmt.Sprintf("%s.v%d.bak", s.Path, from)
+}
+
+// removeLeftoverTemps deletes temporary files of writes that never
+// completed. It must only be called with the lock held, otherwise it could
+// remove the temporary file of a write in progress.
+func (s *JSONFileStore) removeLeftoverTemps() {
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(s.Path), tempPrefix(s.Path)+"*"))
+	for _, name := range matches {
+		os.Remove(name)
+	}
+}
+
+// lock takes the store's file lock, creating the task file's directory if
+// needed.
+func (s *JSONFileStore) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return nil, err
+	}
+	return lockFile(s.Path+".lock", lockTimeout(s))
+}
+
+// lockTimeout returns how long the store underneath s waits for the locks
+// of other processes.
+func lockTimeout(s TaskStore) time.Duration {
+	var timeout time.Duration
+	switch base := BaseStore(s).(type) {
+	case *JSONFileStore:
+		timeout = base.LockTimeout
+	case *JournalStore:
+		timeout = base.Snapshot.LockTimeout
+	case *DirStore:
+		timeout = base.LockTimeout
+	}
+	if timeout == 0 {
+		return DefaultLockTimeout
+	}
+	return timeout
+}
+
+// lockPath returns the task file whose lock the store underneath s takes,
+// or "" for stores without one.
+func lockPath(s TaskStore) string {
+	switch base := BaseStore(s).(type) {
+	case *JSONFileStore:
+		return base.Path
+	case *JournalStore:
+		return base.Snapshot.Path
+	case *DirStore:
+		return base.Path
+	default:
+		return ""
+	}
+}
+
+// Save writes the task list to the file atomically, keeping the previous
+// version as the backup copy.
+func (s *JSONFileStore) Save(list *TaskList) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.save(list)
+}
+
+// save is Save for callers that already hold the lock.
+func (s *JSONFileStore) save(list *TaskList) error {
+	list.fixNextID()
+	s.mu.Lock()
+	plain, err := encodeTaskListReusing(list, s.cache.encoded)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	data := plain
+	if fileEncrypted(s.Path) {
+		if data, err = s.Encryption.seal(data); err != nil {
+			return err
+		}
+	}
+	s.removeLeftoverTemps()
+
+	if s.Backups.Enabled() {
+		if err := writeRotatingBackup(s.Path, s.Backups, time.Now()); err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+	}
+
+	// Keep the current file as the last good copy. The rename below gives
+	// the task file a new inode, so a hard link is enough; fall back to a
+	// copy where links are not supported.
+	if _, err := os.Stat(s.Path); err == nil {
+		os.Remove(s.backupPath())
+		if err := os.Link(s.Path, s.backupPath()); err != nil {
+			old, err := os.ReadFile(s.Path)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(s.backupPath(), old, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := writeFileAtomic(s.Path, data, 0644); err != nil {
+		return err
+	}
+
+	// Other task-cli processes replace the file rather than write to it,
+	// so the list saved stays what the file holds until the file changes;
+	// a long-running process such as the daemon need not read it back
+	if info, err := os.Stat(s.Path); err == nil {
+		s.mu.Lock()
+		s.cache = fileCache{info: info, list: list.Clone(), encoded: splitTasks(plain, list.Tasks)}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// RestoreBackup replaces the task file with the last good copy. The damaged
+// file is kept next to it with a ".corrupt" suffix.
+func (s *JSONFileStore) RestoreBackup() error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.backupPath())
+	if err != nil {
+		return err
+	}
+	if _, err := s.decode(data); err != nil {
+		return fmt.Errorf("backup %s is damaged too: %w", s.backupPath(), err)
+	}
+	if err := os.Rename(s.Path, s.corruptPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return writeFileAtomic(s.Path, data, 0644)
+}
+
+// Get returns the task with the given ID.
+func (s *JSONFileStore) Get(id int) (Task, error) {
+	list, err := s.Load()
+	if err != nil {
+		return Task{}, err
+	}
+	return getTask(list, id)
+}
+
+// Modify runs fn on the task list in the file and writes the result back.
+// The whole read-modify-write cycle runs under the file lock.
+func (s *JSONFileStore) Modify(fn func(list *TaskList) error) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	list, err := s.readRepaired()
+	if err != nil {
+		return err
+	}
+	if err := fn(list); err != nil {
+		return err
+	}
+	return s.save(list)
+}
+
+// backupBeforeMigration copies the task file, still in format version
+// from, aside before it is saved in the current format.
+func (s *JSONFileStore) backupBeforeMigration(from int) error {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	backup := s.migrationBackupPath(from)
+	if err := writeFileAtomic(backup, data, 0644); err != nil {
+		return fmt.Errorf("backing up task file before upgrading it: %w", err)
+	}
+	fmt.Fprintf(Notices, "Upgraded %s from format version %d to %d; the original is kept in %s\n", s.Path, from, formatVersion, backup)
+	return nil
+}
+
+// reportIDChanges tells the user about tasks that were given a new ID.
+func reportIDChanges(changes []IDChange) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintln(Notices, "Found duplicate task IDs; renumbered:")
+	for _, c := range changes {
+		fmt.Fprintf(Notices, "  %d -> %d (%s)\n", c.OldID, c.NewID, c.Description)
+	}
+}
+
+// MemoryStore keeps the task list in memory. It is safe for concurrent use.
+type MemoryStore struct {
+	mu   sync.Mutex
+	list TaskList
+}
+
+// NewMemoryStore returns a store holding a copy of tasks.
+func NewMemoryStore(tasks ...Task) *MemoryStore {
+	s := &MemoryStore{list: TaskList{Tasks: append([]Task{}, tasks...)}}
+	s.list.fixNextID()
+	return s
+}
+
+// Load returns a copy of the stored task list.
+func (s *MemoryStore) Load() (*TaskList, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list.Clone(), nil
+}
+
+// Save replaces the stored task list with a copy of list.
+func (s *MemoryStore) Save(list *TaskList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = *list.Clone()
+	s.list.fixNextID()
+	return nil
+}
+
+// Get returns the task with the given ID.
+func (s *MemoryStore) Get(id int) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return getTask(&s.list, id)
+}
+
+// Modify runs fn on a copy of the stored task list and keeps the result.
+func (s *MemoryStore) Modify(fn func(list *TaskList) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := s.list.Clone()
+	if err := fn(list); err != nil {
+		return err
+	}
+	list.fixNextID()
+	s.list = *list
+	return nil
+}
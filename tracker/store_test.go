@@ -0,0 +1,145 @@
+package tracker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestMemoryStoreCommands tests the task commands against an injected MemoryStore.
+//
+// The test includes the following cases:
+//
+//  1. Add, update and mark: Run the commands against the memory store. The test
+//     checks that the store holds the expected task afterwards.
+//
+//  2. Delete: Delete the task. The test checks that the store keeps it in the
+//     trash.
+func TestMemoryStoreCommands(t *testing.T) {
+	// Setup: Gunakan MemoryStore selama test berjalan
+	mem := NewMemoryStore()
+	tr := New(mem)
+
+	// Case 1: Tambah, update dan tandai task
+	if _, err := tr.Add("In memory"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := tr.Update(1, "Still in memory"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := tr.Mark(1, "done"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	task, err := mem.Get(1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if task.Description != "Still in memory" || task.Status != "done" {
+		t.Errorf("Unexpected task in store: %+v", task)
+	}
+
+	// Case 2: Hapus task
+	if _, err := tr.Delete(1); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if task, err := mem.Get(1); err != nil || !task.Trashed() {
+		t.Fatalf("Expected task 1 in the trash, got %+v (err %v)", task, err)
+	}
+}
+
+// TestJSONFileStore tests that JSONFileStore round-trips tasks through its file.
+//
+// The test includes the following cases:
+//
+//  1. Missing file: Load from a path that does not exist. The test checks that an
+//     empty list is returned without error.
+//
+//  2. Modify and Get: Add a task through Modify. The test checks that Get returns it.
+func TestJSONFileStore(t *testing.T) {
+	s := NewJSONFileStore(filepath.Join(t.TempDir(), "tasks.json"))
+
+	// Case 1: File belum ada
+	list, err := s.Load()
+	if err != nil || len(list.Tasks) != 0 {
+		t.Fatalf("Expected empty list, got %v (err %v)", list, err)
+	}
+
+	// Case 2: Modify lalu Get
+	err = s.Modify(func(list *TaskList) error {
+		list.Tasks = append(list.Tasks, Task{ID: 7, Description: "File task", Status: "todo", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	task, err := s.Get(7)
+	if err != nil || task.Description != "File task" {
+		t.Fatalf("Expected task 7, got %+v (err %v)", task, err)
+	}
+}
+
+// TestJSONFileStoreRecovery tests the crash-safety of JSONFileStore.
+//
+// The test includes the following cases:
+//
+//  1. Atomic save: Save twice. The test checks that no temporary file is left
+//     behind and that the previous version is kept as the backup copy.
+//
+//  2. Leftover temp file: Leave a temp file from an interrupted write. The test
+//     checks that Load still succeeds and that the next Save removes it.
+//
+//  3. Damaged file: Truncate the task file. The test checks that Load returns a
+//     *CorruptFileError pointing at the backup, and that RestoreBackup brings
+//     back the last good copy.
+func TestJSONFileStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.json")
+	s := NewJSONFileStore(path)
+
+	// Case 1: Simpan dua kali secara atomik
+	if err := s.Save(&TaskList{Tasks: []Task{{ID: 1, Description: "First", Status: "todo"}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := s.Save(&TaskList{Tasks: []Task{{ID: 1, Description: "First", Status: "todo"}, {ID: 2, Description: "Second", Status: "todo"}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if temps, _ := filepath.Glob(filepath.Join(dir, ".tasks.json.tmp-*")); len(temps) != 0 {
+		t.Errorf("Expected no temp files, got %v", temps)
+	}
+	backup, err := readTaskListFile(nil, path+".bak")
+	if err != nil || len(backup.Tasks) != 1 {
+		t.Fatalf("Expected backup with 1 task, got %v (err %v)", backup, err)
+	}
+
+	// Case 2: File temp sisa dari penulisan yang terputus
+	leftover := filepath.Join(dir, ".tasks.json.tmp-123")
+	os.WriteFile(leftover, []byte(`[{"id":1`), 0644)
+	list, err := s.Load()
+	if err != nil || len(list.Tasks) != 2 {
+		t.Fatalf("Expected 2 tasks, got %v (err %v)", list, err)
+	}
+	if err := s.Save(list); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+		t.Errorf("Expected leftover temp file to be removed")
+	}
+
+	// Case 3: File task rusak
+	os.WriteFile(path, []byte(`[{"id":1,"descr`), 0644)
+	_, err = s.Load()
+	var cerr *CorruptFileError
+	if !errors.As(err, &cerr) || cerr.Backup != path+".bak" {
+		t.Fatalf("Expected CorruptFileError with backup, got %v", err)
+	}
+	if err := s.RestoreBackup(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	list, err = s.Load()
+	if err != nil || len(list.Tasks) != 2 || list.Tasks[1].Description != "Second" {
+		t.Fatalf("Expected restored task list, got %v (err %v)", list, err)
+	}
+}
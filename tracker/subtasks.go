@@ -0,0 +1,233 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChildPolicy says what happens to the subtasks of a task that is deleted
+// or closed.
+type ChildPolicy string
+
+// The child policies.
+const (
+	// ChildrenBlock refuses to delete a task that has subtasks or to close
+	// one that has open subtasks. It is the default.
+	ChildrenBlock ChildPolicy = "block"
+	// ChildrenCascade deletes the subtasks along with the task, or closes
+	// the open ones with the same status.
+	ChildrenCascade ChildPolicy = "cascade"
+	// ChildrenOrphan detaches the subtasks, or the open ones when the task
+	// is closed, so they become top-level tasks.
+	ChildrenOrphan ChildPolicy = "orphan"
+)
+
+// ParseChildPolicy returns the child policy named s.
+func ParseChildPolicy(s string) (ChildPolicy, error) {
+	switch policy := ChildPolicy(s); policy {
+	case ChildrenBlock, ChildrenCascade, ChildrenOrphan:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown subtask policy %q (want block, cascade or orphan)", s)
+}
+
+var (
+	// ErrHasSubtasks is returned by Delete and Mark when the subtasks of
+	// the task stand in the way under ChildrenBlock.
+	ErrHasSubtasks = errors.New("task has subtasks")
+	// ErrInvalidParent is returned for parents that do not exist, are in
+	// the trash or would make a task its own ancestor.
+	ErrInvalidParent = errors.New("invalid parent task")
+)
+
+// childPolicy returns the tracker's child policy.
+func (t *Tracker) childPolicy() ChildPolicy {
+	if t.Subtasks == "" {
+		return ChildrenBlock
+	}
+	return t.Subtasks
+}
+
+// checkParent returns an error unless the task with ID parent is outside
+// the trash of list and is neither the task with the given ID nor one of
+// its subtasks. id is 0 for new tasks.
+func checkParent(list *TaskList, id, parent int) error {
+	for seen := 0; parent != 0; seen++ {
+		i := list.LiveIndex(parent)
+		if i < 0 {
+			return fmt.Errorf("%w %d: %w", ErrInvalidParent, parent, ErrTaskNotFound)
+		}
+		if parent == id || seen > len(list.Tasks) {
+			return fmt.Errorf("%w %d: task %d cannot be its own subtask", ErrInvalidParent, parent, id)
+		}
+		parent = list.Tasks[i].ParentID
+	}
+	return nil
+}
+
+// subtasks returns the indexes of the tasks outside the trash of list whose
+// parent is the task with the given ID, in file order. With all it returns
+// their subtasks too, each after its parent.
+func subtasks(list *TaskList, id int, all bool) []int {
+	children := make(map[int][]int)
+	for i, task := range list.Tasks {
+		if task.ParentID != 0 && !task.Trashed() {
+			children[task.ParentID] = append(children[task.ParentID], i)
+		}
+	}
+
+	var found []int
+	seen := map[int]bool{id: true}
+	var walk func(id int)
+	walk = func(id int) {
+		for _, i := range children[id] {
+			if seen[list.Tasks[i].ID] {
+				continue
+			}
+			seen[list.Tasks[i].ID] = true
+			found = append(found, i)
+			if all {
+				walk(list.Tasks[i].ID)
+			}
+		}
+	}
+	walk(id)
+	return found
+}
+
+// deleteTask moves the task at index i of list to the trash at now and
+// deals with its subtasks as the tracker's child policy says.
+func (t *Tracker) deleteTask(list *TaskList, i int, now time.Time) error {
+	task := &list.Tasks[i]
+	switch children := subtasks(list, task.ID, t.childPolicy() == ChildrenCascade); {
+	case len(children) == 0:
+	case t.childPolicy() == ChildrenCascade:
+		for _, c := range children {
+			list.Tasks[c].DeletedAt = &now
+		}
+	case t.childPolicy() == ChildrenOrphan:
+		for _, c := range children {
+			list.Tasks[c].ParentID = 0
+			list.Tasks[c].UpdatedAt = now
+		}
+	default:
+		return fmt.Errorf("%w (%d); delete or move them first", ErrHasSubtasks, len(children))
+	}
+	task.DeletedAt = &now
+	return nil
+}
+
+// closeSubtasks deals with the open subtasks of the task at index i of
+// list, which is being closed with the given status, as the tracker's child
+// policy says.
+func (t *Tracker) closeSubtasks(list *TaskList, i int, status Status, now time.Time) error {
+	workflow := t.workflow()
+	id := list.Tasks[i].ID
+	var open []int
+	for _, c := range subtasks(list, id, t.childPolicy() == ChildrenCascade) {
+		if !workflow.Closed(list.Tasks[c].Status) {
+			open = append(open, c)
+		}
+	}
+	switch {
+	case len(open) == 0:
+	case t.childPolicy() == ChildrenCascade:
+		for _, c := range open {
+			child := &list.Tasks[c]
+			if !workflow.CanTransition(child.Status, status) {
+				return fmt.Errorf("subtask of task %d: %w", id, workflow.transitionError(*child, status))
+			}
+			child.Status = status
+			child.UpdatedAt = now
+		}
+	case t.childPolicy() == ChildrenOrphan:
+		for _, c := range open {
+			list.Tasks[c].ParentID = 0
+			list.Tasks[c].UpdatedAt = now
+		}
+	default:
+		return fmt.Errorf("%w (%d open); finish them first", ErrHasSubtasks, len(open))
+	}
+	return nil
+}
+
+// Progress counts the subtasks of a task and how many of them are closed.
+type Progress struct {
+	Done, Total int
+}
+
+// TreeNode is a task in a task tree with its depth, 0 for top-level tasks,
+// and the progress of its direct subtasks.
+type TreeNode struct {
+	Task
+	Depth    int
+	Progress Progress
+}
+
+// QueryTree returns the tasks that are not in the trash and match q as a
+// tree: each task is followed by its subtasks, in the order of q. Subtasks
+// whose parent q leaves out are shown at the top level. Progress counts all
+// subtasks outside the trash, whether q matches them or not.
+func (t *Tracker) QueryTree(q Query) ([]TreeNode, error) {
+	if q.Now.IsZero() {
+		q.Now = t.Now()
+	}
+	if len(q.Workflow) == 0 {
+		q.Workflow = t.workflow()
+	}
+	tasks, err := t.Tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(map[int]Progress)
+	for _, task := range tasks {
+		if task.ParentID == 0 {
+			continue
+		}
+		p := progress[task.ParentID]
+		p.Total++
+		if q.Workflow.Closed(task.Status) {
+			p.Done++
+		}
+		progress[task.ParentID] = p
+	}
+
+	matched := q.Apply(tasks)
+	shown := make(map[int]bool, len(matched))
+	for _, task := range matched {
+		shown[task.ID] = true
+	}
+	children := make(map[int][]Task)
+	var roots []Task
+	for _, task := range matched {
+		if task.ParentID != 0 && task.ParentID != task.ID && shown[task.ParentID] {
+			children[task.ParentID] = append(children[task.ParentID], task)
+		} else {
+			roots = append(roots, task)
+		}
+	}
+
+	tree := make([]TreeNode, 0, len(matched))
+	placed := make(map[int]bool, len(matched))
+	var place func(task Task, depth int)
+	place = func(task Task, depth int) {
+		if placed[task.ID] {
+			return
+		}
+		placed[task.ID] = true
+		tree = append(tree, TreeNode{Task: task, Depth: depth, Progress: progress[task.ID]})
+		for _, child := range children[task.ID] {
+			place(child, depth+1)
+		}
+	}
+	for _, task := range roots {
+		place(task, 0)
+	}
+	// Tasks in a parent cycle, which doctor reports, have no root
+	for _, task := range matched {
+		place(task, 0)
+	}
+	return tree, nil
+}
@@ -0,0 +1,165 @@
+package tracker
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestSubtasks tests subtasks, the task tree and the child policies.
+//
+// The test includes the following cases:
+//
+//  1. Parent: Add a parent with two subtasks and a subtask of a subtask.
+//     The test checks that missing parents and parents that would make a
+//     task its own subtask are refused.
+//
+//  2. Tree: Finish a subtask. The test checks that subtasks follow their
+//     parent, indented, that the parent shows 1 of 2 subtasks done and that
+//     subtasks of parents left out of the query are top-level.
+//
+//  3. Block: The test checks that a parent with open subtasks can be
+//     neither done nor deleted under the default policy.
+//
+//  4. Cascade: Mark the parent done, reopen it and delete it. The test
+//     checks that the open subtasks, down to the last level, are done and
+//     then deleted with it, and that restoring it brings them back.
+//
+//  5. Orphan: Delete the parent. The test checks that its subtasks are
+//     top-level tasks.
+func TestSubtasks(t *testing.T) {
+	tr := New(NewMemoryStore())
+
+	// Case 1: Menambah subtask
+	tr.Add("Release")
+	tr.AddTask(Task{Description: "Notes", ParentID: 1})
+	tr.AddTask(Task{Description: "Build", ParentID: 1})
+	tr.AddTask(Task{Description: "Changelog", ParentID: 2})
+	if _, err := tr.AddTask(Task{Description: "Lost", ParentID: 9}); !errors.Is(err, ErrInvalidParent) || !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected a missing parent to be refused, got %v", err)
+	}
+	parent := 4
+	if _, err := tr.UpdateTask(1, TaskUpdate{ParentID: &parent}); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("Expected a parent cycle to be refused, got %v", err)
+	}
+
+	// Case 2: Pohon task dan progress
+	tr.Mark(3, StatusDone)
+	tree, err := tr.QueryTree(Query{Sort: SortID})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var ids, depths []int
+	for _, node := range tree {
+		ids, depths = append(ids, node.ID), append(depths, node.Depth)
+	}
+	if len(tree) != 4 || ids[1] != 2 || ids[2] != 4 || depths[2] != 2 || depths[3] != 1 {
+		t.Errorf("Expected 1 > 2 > 4 and 1 > 3, got IDs %v at depths %v", ids, depths)
+	}
+	if tree[0].Progress != (Progress{Done: 1, Total: 2}) {
+		t.Errorf("Expected 1/2 done, got %+v", tree[0].Progress)
+	}
+	tree, _ = tr.QueryTree(Query{Statuses: []Status{StatusTodo}, Sort: SortID})
+	if len(tree) != 3 || tree[2].ID != 4 || tree[2].Depth != 2 || tree[1].Depth != 1 {
+		t.Errorf("Expected the todo tasks as a tree, got %+v", tree)
+	}
+	tree, _ = tr.QueryTree(Query{Statuses: []Status{StatusDone}})
+	if len(tree) != 1 || tree[0].ID != 3 || tree[0].Depth != 0 {
+		t.Errorf("Expected task 3 at the top level, got %+v", tree)
+	}
+
+	// Case 3: Policy block
+	if _, err := tr.Mark(1, StatusDone); !errors.Is(err, ErrHasSubtasks) {
+		t.Errorf("Expected ErrHasSubtasks, got %v", err)
+	}
+	if _, err := tr.Delete(1); !errors.Is(err, ErrHasSubtasks) {
+		t.Errorf("Expected ErrHasSubtasks, got %v", err)
+	}
+
+	// Case 4: Policy cascade
+	tr.Subtasks = ChildrenCascade
+	if _, err := tr.Mark(1, StatusDone); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, id := range []int{2, 4} {
+		if task, _ := tr.Get(id); task.Status != StatusDone {
+			t.Errorf("Expected subtask %d done, got %s", id, task.Status)
+		}
+	}
+	tr.Reopen(1)
+	tr.Delete(1)
+	if tasks, _ := tr.Tasks(); len(tasks) != 0 {
+		t.Errorf("Expected every task in the trash, got %+v", tasks)
+	}
+	tr.Restore(1)
+	if tasks, _ := tr.Tasks(); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks restored, got %+v", tasks)
+	}
+
+	// Case 5: Policy orphan
+	tr.Subtasks = ChildrenOrphan
+	tr.Delete(1)
+	tree, _ = tr.QueryTree(Query{Sort: SortID})
+	if len(tree) != 3 || tree[0].ID != 2 || tree[0].ParentID != 0 || tree[1].ID != 4 || tree[1].Depth != 1 || tree[2].ID != 3 || tree[2].ParentID != 0 {
+		t.Errorf("Expected 2 > 4 and 3 at the top level, got %+v", tree)
+	}
+}
+
+// TestSubtaskIDs tests that subtasks keep their parent where task IDs
+// change.
+//
+// The test includes the following cases:
+//
+//  1. Sync: Sync a parent and its subtask to a file where their IDs are
+//     taken. The test checks that the subtask points at the parent's new ID.
+//
+//  2. Merge: Merge a parent and its subtask added on their side with a task
+//     added on ours under the parent's ID. The test checks that the subtask
+//     follows the renumbered parent.
+//
+//  3. Doctor: The test checks that a missing parent and a parent cycle are
+//     reported and that repairing drops the parent of the missing one and of
+//     one task of the cycle.
+func TestSubtaskIDs(t *testing.T) {
+	// Case 1: Sync
+	local := &TaskList{}
+	local.Add(Task{Description: "Release"})
+	local.Add(Task{Description: "Notes", ParentID: 1})
+	remote := &TaskList{}
+	remote.Add(Task{Description: "Other"})
+	remote.Add(Task{Description: "Other too"})
+	syncTaskLists(local, remote, PreferNewer)
+	byUID := uidIndex(remote)
+	release, notes := remote.Tasks[byUID[local.Tasks[0].UID]], remote.Tasks[byUID[local.Tasks[1].UID]]
+	if release.ID == 1 || notes.ParentID != release.ID {
+		t.Errorf("Expected the subtask under ID %d, got %+v", release.ID, notes)
+	}
+	if local.Tasks[1].ParentID != 1 {
+		t.Errorf("Expected the local subtask under ID 1, got %+v", local.Tasks[1])
+	}
+
+	// Case 2: Merge
+	base := &TaskList{NextID: 1}
+	ours := &TaskList{}
+	ours.Add(Task{Description: "Mine"})
+	theirs := &TaskList{}
+	theirs.Add(Task{Description: "Release"})
+	theirs.Add(Task{Description: "Notes", ParentID: 1})
+	merged := MergeTaskLists(base, ours, theirs).List
+	if i, j := merged.Index(2), merged.Index(3); i < 0 || j < 0 || merged.Tasks[i].ParentID != 3 || merged.Tasks[j].Description != "Release" {
+		t.Errorf("Expected the subtask under the parent renumbered to 3, got %+v", merged.Tasks)
+	}
+
+	// Case 3: Doctor
+	list := &TaskList{Tasks: []Task{
+		{ID: 1, Description: "Lost", Status: StatusTodo, ParentID: 7},
+		{ID: 2, Description: "Chicken", Status: StatusTodo, ParentID: 3},
+		{ID: 3, Description: "Egg", Status: StatusTodo, ParentID: 2},
+	}}
+	if problems := CheckTasks(list, DefaultWorkflow); len(problems) != 3 {
+		t.Errorf("Expected 3 problems, got %v", problems)
+	}
+	RepairTasks(list, DefaultWorkflow)
+	if list.Tasks[0].ParentID != 0 || list.Tasks[1].ParentID != 0 || list.Tasks[2].ParentID != 2 {
+		t.Errorf("Expected the parents of 1 and 2 dropped, got %+v", list.Tasks)
+	}
+}
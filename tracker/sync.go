@@ -0,0 +1,268 @@
+package tracker
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+)
+
+// errUnchanged is returned by the Modify callbacks of Sync to skip saving a
+// task list that did not change.
+var errUnchanged = errors.New("unchanged")
+
+// SyncResult is what Sync changed on each side.
+type SyncResult struct {
+	Local  []TaskChange
+	Remote []TaskChange
+}
+
+// syncTaskLists reconciles local and remote in place, matching tasks by UID
+// rather than ID, since the two files may give a task different IDs:
+//
+//   - A task on one side only is added to the other with the next free ID,
+//     unless the other side has a tombstone for it that is newer than the
+//     task's last change; then it is removed, leaving a tombstone.
+//   - A task that differs between the sides takes the version preferRemote
+//     picks, keeping each side's ID.
+//   - Parents are matched by UID too, so subtasks keep their parent under
+//     that parent's ID on each side.
+//
+// Both sides end up with the same tasks, UIDs and tombstones, so syncing
+// again changes nothing.
+func syncTaskLists(local, remote *TaskList, preferRemote func(local, remote Task) bool) {
+	for _, list := range []*TaskList{local, remote} {
+		for i := range list.Tasks {
+			list.Tasks[i].UID = list.Tasks[i].uid()
+		}
+	}
+	tombstones := make(map[string]Tombstone)
+	for _, t := range mergeTombstones(nil, local.Tombstones, remote.Tombstones) {
+		tombstones[t.UID] = t
+	}
+
+	// copyMissing brings the tasks of from that to lacks over to to, or
+	// removes them from from if they were removed from to after their last
+	// change.
+	copied := map[*TaskList]map[string]string{local: {}, remote: {}}
+	copyMissing := func(from, to *TaskList) {
+		have := uidIndex(to)
+		parents := parentUIDs(from)
+		for _, task := range append([]Task(nil), from.Tasks...) {
+			if _, ok := have[task.UID]; ok {
+				continue
+			}
+			if t, ok := tombstones[task.UID]; ok && !task.lastChange().After(t.At) {
+				from.removeAt(task.ID, t.At)
+				continue
+			}
+			to.Add(task)
+			copied[to][task.UID] = parents[task.UID]
+		}
+	}
+	copyMissing(local, remote)
+	copyMissing(remote, local)
+	// Parents may be copied after their subtasks, so link them last
+	for _, list := range []*TaskList{local, remote} {
+		byUID := uidIndex(list)
+		for uid, parent := range copied[list] {
+			list.Tasks[byUID[uid]].ParentID = parentID(list, byUID, parent)
+		}
+	}
+
+	localByUID, remoteByUID := uidIndex(local), uidIndex(remote)
+	localParents, remoteParents := parentUIDs(local), parentUIDs(remote)
+	for i, task := range local.Tasks {
+		j, ok := remoteByUID[task.UID]
+		if !ok {
+			continue
+		}
+		other := remote.Tasks[j]
+		other.ID = task.ID
+		other.ParentID = parentID(local, localByUID, remoteParents[task.UID])
+		if sameTask(task, other) {
+			continue
+		}
+		if preferRemote(task, remote.Tasks[j]) {
+			local.Tasks[i] = other
+		} else {
+			task.ID = remote.Tasks[j].ID
+			task.ParentID = parentID(remote, remoteByUID, localParents[task.UID])
+			remote.Tasks[j] = task
+		}
+	}
+
+	// Both sides keep the same tombstones, except for tasks that came back
+	var all []Tombstone
+	for _, t := range tombstones {
+		all = append(all, t)
+	}
+	local.Tombstones = mergeTombstones(local.Tasks, all)
+	remote.Tombstones = mergeTombstones(remote.Tasks, all)
+}
+
+// uidIndex maps the UIDs of the tasks in list to their index.
+func uidIndex(list *TaskList) map[string]int {
+	index := make(map[string]int, len(list.Tasks))
+	for i, task := range list.Tasks {
+		index[task.uid()] = i
+	}
+	return index
+}
+
+// parentUIDs maps the UIDs of the subtasks in list to the UIDs of their
+// parents.
+func parentUIDs(list *TaskList) map[string]string {
+	byID := make(map[int]string, len(list.Tasks))
+	for _, task := range list.Tasks {
+		byID[task.ID] = task.uid()
+	}
+	parents := make(map[string]string)
+	for _, task := range list.Tasks {
+		if uid, ok := byID[task.ParentID]; ok && task.ParentID != 0 {
+			parents[task.uid()] = uid
+		}
+	}
+	return parents
+}
+
+// parentID returns the ID in list of the task with the UID parent, or 0 if
+// parent is empty or list lacks it. byUID is the uidIndex of list.
+func parentID(list *TaskList, byUID map[string]int, parent string) int {
+	if i, ok := byUID[parent]; ok && parent != "" {
+		return list.Tasks[i].ID
+	}
+	return 0
+}
+
+// mergeTombstones combines tombstone lists, keeping the newest tombstone for
+// each UID, and drops the tombstones of the given tasks, which are alive.
+func mergeTombstones(tasks []Task, lists ...[]Tombstone) []Tombstone {
+	alive := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		alive[task.uid()] = true
+	}
+	byUID := make(map[string]Tombstone)
+	for _, list := range lists {
+		for _, t := range list {
+			if old, ok := byUID[t.UID]; !alive[t.UID] && (!ok || t.At.After(old.At)) {
+				byUID[t.UID] = t
+			}
+		}
+	}
+	var merged []Tombstone
+	for _, t := range byUID {
+		merged = append(merged, t)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].UID < merged[j].UID })
+	return merged
+}
+
+// PreferNewer picks the remote task if it was changed last. It is the
+// default way for Sync to settle tasks that differ.
+func PreferNewer(local, remote Task) bool {
+	return remote.lastChange().After(local.lastChange())
+}
+
+// Sync reconciles the tracker's tasks with those of remote (see
+// syncTaskLists) and saves whichever sides changed. preferRemote settles
+// tasks that differ; PreferNewer picks the last change. localPath and
+// remotePath name the two task files; they decide the order in which the
+// stores are locked, so that two syncs between the same files cannot
+// deadlock.
+func (t *Tracker) Sync(localPath string, remote TaskStore, remotePath string, preferRemote func(local, remote Task) bool) (SyncResult, error) {
+	var result SyncResult
+	localAbs, _ := filepath.Abs(localPath)
+	remoteAbs, _ := filepath.Abs(remotePath)
+	if localAbs == remoteAbs {
+		return result, errors.New("cannot sync a task file with itself")
+	}
+
+	reconcile := func(local, remote *TaskList) (localChanged, remoteChanged bool) {
+		localBefore, remoteBefore := local.Clone(), remote.Clone()
+		syncTaskLists(local, remote, preferRemote)
+		result.Local = DiffTaskLists(localBefore, local)
+		result.Remote = DiffTaskLists(remoteBefore, remote)
+		return !sameTaskList(localBefore, local), !sameTaskList(remoteBefore, remote)
+	}
+
+	var err error
+	if localAbs < remoteAbs {
+		err = modifyBoth(t.Store, remote, reconcile)
+	} else {
+		err = modifyBoth(remote, t.Store, func(remote, local *TaskList) (bool, bool) {
+			localChanged, remoteChanged := reconcile(local, remote)
+			return remoteChanged, localChanged
+		})
+	}
+	return result, err
+}
+
+// modifyBoth modifies the task lists of first and second together, holding
+// both locks, and saves each one fn reports as changed.
+func modifyBoth(first, second TaskStore, fn func(a, b *TaskList) (aChanged, bChanged bool)) error {
+	err := first.Modify(func(a *TaskList) error {
+		var aChanged bool
+		err := second.Modify(func(b *TaskList) error {
+			var bChanged bool
+			aChanged, bChanged = fn(a, b)
+			if !bChanged {
+				return errUnchanged
+			}
+			return nil
+		})
+		if err != nil && err != errUnchanged {
+			return err
+		}
+		if !aChanged {
+			return errUnchanged
+		}
+		return nil
+	})
+	if err == errUnchanged {
+		return nil
+	}
+	return err
+}
+
+// sameTaskList reports whether a and b would be stored identically.
+func sameTaskList(a, b *TaskList) bool {
+	if a.NextID != b.NextID || len(a.Tasks) != len(b.Tasks) || !sameTombstones(a.Tombstones, b.Tombstones) {
+		return false
+	}
+	for i := range a.Tasks {
+		if !sameTask(a.Tasks[i], b.Tasks[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTombstones reports whether a and b hold the same tombstones in the
+// same order.
+func sameTombstones(a, b []Tombstone) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].UID != b[i].UID || !a[i].At.Equal(b[i].At) {
+			return false
+		}
+	}
+	return true
+}
+
+// VisibleChanges drops the changes that only gave a task its UID.
+func VisibleChanges(changes []TaskChange) []TaskChange {
+	var visible []TaskChange
+	for _, c := range changes {
+		if c.Before != nil && c.After != nil {
+			before := *c.Before
+			before.UID = c.After.UID
+			if sameTask(before, *c.After) {
+				continue
+			}
+		}
+		visible = append(visible, c)
+	}
+	return visible
+}
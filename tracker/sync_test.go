@@ -0,0 +1,135 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestSync tests reconciling two task files.
+//
+// The test includes the following cases:
+//
+//  1. Legacy files: Sync two copies of a task file written before tasks had
+//     UIDs. The test checks that the copies of each task are matched and
+//     nothing is duplicated.
+//
+//  2. Adds and edits: Add a task on each side and edit the same task on both.
+//     The test checks that both new tasks exist on both sides and that the
+//     later edit wins.
+//
+//  3. Deletions: Purge a task on one side and trash another on the other
+//     side. The test checks that the purge is carried over through its
+//     tombstone and the trash state is carried over too.
+//
+//  4. Idempotent: Sync again. The test checks that nothing changes.
+//
+//  5. Changed after removal: Change a task on one side after it was purged
+//     on the other. The test checks that the task comes back on both sides.
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	localPath, remotePath := filepath.Join(dir, "laptop.json"), filepath.Join(dir, "work.json")
+	legacy := `{"version": 2, "meta": {"nextId": 4}, "tasks": [
+		{"id": 1, "description": "One", "status": "todo", "createdAt": "2024-01-01T09:00:00Z", "updatedAt": "2024-01-01T09:00:00Z"},
+		{"id": 2, "description": "Two", "status": "todo", "createdAt": "2024-01-01T09:01:00Z", "updatedAt": "2024-01-01T09:01:00Z"},
+		{"id": 3, "description": "Three", "status": "todo", "createdAt": "2024-01-01T09:02:00Z", "updatedAt": "2024-01-01T09:02:00Z"}
+	]}`
+	os.WriteFile(localPath, []byte(legacy), 0644)
+	os.WriteFile(remotePath, []byte(legacy), 0644)
+	local, remote := NewJSONFileStore(localPath), NewJSONFileStore(remotePath)
+	tr := New(local)
+	sync := func() SyncResult {
+		result, err := tr.Sync(localPath, remote, remotePath, PreferNewer)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return result
+	}
+	descriptions := func(s TaskStore) map[string]bool {
+		list, _ := s.Load()
+		found := make(map[string]bool)
+		for _, task := range list.Live() {
+			found[task.Description] = true
+		}
+		return found
+	}
+
+	// Case 1: File lama tanpa UID
+	result := sync()
+	if len(VisibleChanges(result.Local)) != 0 || len(VisibleChanges(result.Remote)) != 0 {
+		t.Errorf("Expected no visible changes, got %+v", result)
+	}
+	if list, _ := remote.Load(); len(list.Tasks) != 3 || list.Tasks[0].UID == "" {
+		t.Errorf("Expected 3 tasks with UIDs, got %+v", list.Tasks)
+	}
+
+	// Case 2: Task baru dan edit di kedua sisi
+	tr.Add("Laptop")
+	tr.Update(1, "One on the laptop")
+	tr.Store = remote
+	tr.Add("Work")
+	time.Sleep(10 * time.Millisecond)
+	tr.Update(1, "One at work")
+	tr.Store = local
+	sync()
+	for _, s := range []TaskStore{local, remote} {
+		found := descriptions(s)
+		if !found["Laptop"] || !found["Work"] || !found["One at work"] || found["One on the laptop"] {
+			t.Errorf("Expected both new tasks and the later edit, got %v", found)
+		}
+	}
+
+	// Case 3: Penghapusan
+	tr.Delete(2)
+	tr.PurgeTrash(0)
+	tr.Store = remote
+	tr.Delete(3)
+	tr.Store = local
+	sync()
+	for _, s := range []TaskStore{local, remote} {
+		list, _ := s.Load()
+		if found := descriptions(s); found["Two"] || found["Three"] {
+			t.Errorf("Expected tasks 2 and 3 to be gone, got %v", found)
+		}
+		if trash := list.Trash(); len(trash) != 1 || trash[0].Description != "Three" {
+			t.Errorf("Expected task 3 in the trash, got %+v", trash)
+		}
+		if len(list.Tombstones) != 1 {
+			t.Errorf("Expected 1 tombstone, got %+v", list.Tombstones)
+		}
+	}
+
+	// Case 4: Sync ulang tidak mengubah apa pun
+	before, _ := os.ReadFile(remotePath)
+	result = sync()
+	if len(result.Local) != 0 || len(result.Remote) != 0 {
+		t.Errorf("Expected no changes, got %+v", result)
+	}
+	if after, _ := os.ReadFile(remotePath); string(after) != string(before) {
+		t.Error("Expected the remote file to be left alone")
+	}
+
+	// Case 5: Task diubah setelah dihapus di sisi lain
+	tr.Store = remote
+	list, _ := remote.Load()
+	var id int
+	for _, task := range list.Live() {
+		if task.Description == "Laptop" {
+			id = task.ID
+		}
+	}
+	tr.Store = local
+	tr.Delete(4)
+	tr.PurgeTrash(0)
+	time.Sleep(10 * time.Millisecond)
+	tr.Store = remote
+	tr.Mark(id, "done")
+	tr.Store = local
+	sync()
+	for _, s := range []TaskStore{local, remote} {
+		if found := descriptions(s); !found["Laptop"] {
+			t.Errorf("Expected the changed task to come back, got %v", found)
+		}
+	}
+}
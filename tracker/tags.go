@@ -0,0 +1,159 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidTag is returned for tags that are empty, contain spaces or
+// commas, or start with "+" or "-".
+var ErrInvalidTag = errors.New("invalid tag")
+
+// ParseTag returns the tag s in lower case, the form tags are stored and
+// matched in.
+func ParseTag(s string) (string, error) {
+	tag := strings.ToLower(s)
+	if tag == "" || strings.HasPrefix(tag, "+") || strings.HasPrefix(tag, "-") ||
+		strings.ContainsFunc(tag, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		return "", fmt.Errorf("%w %q", ErrInvalidTag, s)
+	}
+	return tag, nil
+}
+
+// parseTags parses each of tags with ParseTag.
+func parseTags(tags []string) ([]string, error) {
+	parsed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := ParseTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, tag)
+	}
+	return parsed, nil
+}
+
+// HasTag reports whether the task has the given tag.
+func (t Task) HasTag(tag string) bool {
+	return slices.Contains(t.Tags, tag)
+}
+
+// retag sets the tags of task to its current tags plus add minus remove,
+// sorted and without duplicates, and reports whether they changed.
+func retag(task *Task, add, remove []string) bool {
+	tags := slices.Clone(task.Tags)
+	tags = append(tags, add...)
+	tags = slices.DeleteFunc(tags, func(tag string) bool { return slices.Contains(remove, tag) })
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+	if len(tags) == 0 {
+		tags = nil
+	}
+	if slices.Equal(tags, task.Tags) {
+		return false
+	}
+	task.Tags = tags
+	return true
+}
+
+// Tag adds the tags add to the task with the given ID and removes the tags
+// remove from it, and returns the updated task.
+func (t *Tracker) Tag(id int, add, remove []string) (Task, error) {
+	add, err := parseTags(add)
+	if err != nil {
+		return Task{}, err
+	}
+	remove, err = parseTags(remove)
+	if err != nil {
+		return Task{}, err
+	}
+	return t.modifyTask(id, func(task *Task) error {
+		if retag(task, add, remove) {
+			task.UpdatedAt = time.Now()
+		}
+		return nil
+	})
+}
+
+// TagCount is a tag with the number of tasks outside the trash that have
+// it, by whether their status is open or closed.
+type TagCount struct {
+	Tag          string
+	Open, Closed int
+}
+
+// Tags returns every tag of the tasks outside the trash, sorted, with the
+// number of open and closed tasks that have it.
+func (t *Tracker) Tags() ([]TagCount, error) {
+	tasks, err := t.Tasks()
+	if err != nil {
+		return nil, err
+	}
+	workflow := t.workflow()
+	byTag := make(map[string]*TagCount)
+	var counts []*TagCount
+	for _, task := range tasks {
+		for _, tag := range task.Tags {
+			count, ok := byTag[tag]
+			if !ok {
+				count = &TagCount{Tag: tag}
+				byTag[tag] = count
+				counts = append(counts, count)
+			}
+			if workflow.Closed(task.Status) {
+				count.Closed++
+			} else {
+				count.Open++
+			}
+		}
+	}
+	slices.SortFunc(counts, func(a, b *TagCount) int { return strings.Compare(a.Tag, b.Tag) })
+
+	result := make([]TagCount, len(counts))
+	for i, count := range counts {
+		result[i] = *count
+	}
+	return result, nil
+}
+
+// RenameTag renames the tag from to to on every task, including those in
+// the trash, in a single change, and returns the number of tasks renamed.
+// Tasks that already have to keep it once.
+func (t *Tracker) RenameTag(from, to string) (int, error) {
+	from, err := ParseTag(from)
+	if err != nil {
+		return 0, err
+	}
+	to, err = ParseTag(to)
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return 0, fmt.Errorf("the tag is already called %q", to)
+	}
+
+	var renamed int
+	err = t.Store.Modify(func(list *TaskList) error {
+		renamed = 0
+		now := time.Now()
+		for i := range list.Tasks {
+			task := &list.Tasks[i]
+			if task.HasTag(from) && retag(task, []string{to}, []string{from}) {
+				task.UpdatedAt = now
+				renamed++
+			}
+		}
+		if renamed == 0 {
+			return fmt.Errorf("no task has the tag %q", from)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return renamed, nil
+}
@@ -0,0 +1,92 @@
+package tracker
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestTags tests tagging tasks, counting and renaming tags and listing by
+// them.
+//
+// The test includes the following cases:
+//
+//  1. Tag: Add tasks with tags and change them. The test checks that tags
+//     are stored in lower case, sorted and once, and that invalid tags are
+//     refused.
+//
+//  2. Counts: Mark a task done. The test checks the open and closed counts
+//     of every tag.
+//
+//  3. Query: The test checks that groups of tags all apply, that the tags
+//     in a group are alternatives and that WithoutTags leaves tasks out.
+//
+//  4. Rename: Rename a tag that one task already has under the new name.
+//     The test checks that every task is renamed, including the trash,
+//     without duplicates.
+func TestTags(t *testing.T) {
+	tr := New(NewMemoryStore())
+
+	// Case 1: Menambah dan menghapus tag
+	tr.AddTask(Task{Description: "API", Tags: []string{"Backend", "infra", "backend"}})
+	tr.AddTask(Task{Description: "Docs", Tags: []string{"docs"}})
+	tr.AddTask(Task{Description: "Deploy", Tags: []string{"infra", "ops"}})
+	tr.AddTask(Task{Description: "Old", Tags: []string{"infra"}})
+	task, err := tr.Tag(2, []string{"backend"}, []string{"docs", "missing"})
+	if err != nil || !slices.Equal(task.Tags, []string{"backend"}) {
+		t.Errorf("Expected task 2 tagged backend, got %+v (err %v)", task, err)
+	}
+	if task, _ := tr.Get(1); !slices.Equal(task.Tags, []string{"backend", "infra"}) {
+		t.Errorf("Expected backend and infra, got %v", task.Tags)
+	}
+	for _, tag := range []string{"", "+x", "-x", "a,b", "two words"} {
+		if _, err := tr.Tag(1, []string{tag}, nil); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("Expected ErrInvalidTag for %q, got %v", tag, err)
+		}
+	}
+
+	// Case 2: Jumlah task per tag
+	tr.Mark(1, StatusDone)
+	tr.Delete(4)
+	counts, err := tr.Tags()
+	want := []TagCount{{"backend", 1, 1}, {"infra", 1, 1}, {"ops", 1, 0}}
+	if err != nil || !slices.Equal(counts, want) {
+		t.Errorf("Expected %v, got %v (err %v)", want, counts, err)
+	}
+
+	// Case 3: Filter dengan tag
+	ids := func(q Query) []int {
+		tasks, err := tr.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+	if got := ids(Query{Tags: [][]string{{"backend"}, {"infra"}}}); !slices.Equal(got, []int{1}) {
+		t.Errorf("Expected task 1 for backend and infra, got %v", got)
+	}
+	if got := ids(Query{Tags: [][]string{{"backend", "ops"}}}); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Expected tasks 1, 2 and 3 for backend or ops, got %v", got)
+	}
+	if got := ids(Query{WithoutTags: []string{"infra"}}); !slices.Equal(got, []int{2}) {
+		t.Errorf("Expected task 2 without infra, got %v", got)
+	}
+
+	// Case 4: Ganti nama tag
+	renamed, err := tr.RenameTag("infra", "OPS")
+	if err != nil || renamed != 3 {
+		t.Fatalf("Expected 3 tasks renamed, got %d (err %v)", renamed, err)
+	}
+	for id, want := range map[int][]string{1: {"backend", "ops"}, 3: {"ops"}, 4: {"ops"}} {
+		if task, _ := tr.Get(id); !slices.Equal(task.Tags, want) {
+			t.Errorf("Expected task %d tagged %v, got %v", id, want, task.Tags)
+		}
+	}
+	if _, err := tr.RenameTag("infra", "ops"); err == nil {
+		t.Error("Expected an error renaming a tag no task has")
+	}
+}
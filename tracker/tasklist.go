@@ -0,0 +1,206 @@
+package tracker
+
+import "time"
+
+// TaskList is the content of a task file: the tasks plus the counter used
+// to hand out IDs.
+type TaskList struct {
+	// NextID is the ID the next added task gets. IDs are never reused,
+	// even after the task holding the highest ID is deleted.
+	NextID int
+	Tasks  []Task
+	// Tombstones record the tasks removed for good, so that Sync can
+	// remove them from other task files too.
+	Tombstones []Tombstone
+
+	// index maps IDs to positions in Tasks. It is built by Index and may be
+	// stale; entries are checked before they are used.
+	index map[int]int
+}
+
+// Tombstone records that the task with the given UID was removed for good.
+type Tombstone struct {
+	UID string    `json:"uid"`
+	At  time.Time `json:"at"`
+}
+
+// IDChange records a task that was given a new ID.
+type IDChange struct {
+	OldID       int
+	NewID       int
+	Description string
+}
+
+// Add gives task the next free ID, and a UID if it has none, appends it
+// and returns it.
+func (l *TaskList) Add(task Task) Task {
+	l.fixNextID()
+	if task.UID == "" {
+		task.UID = newUID()
+	}
+	task.ID = l.NextID
+	l.NextID++
+	l.Tasks = append(l.Tasks, task)
+	return task
+}
+
+// Index returns the index of the task with the given ID, or -1.
+func (l *TaskList) Index(id int) int {
+	if i, ok := l.index[id]; ok && i < len(l.Tasks) && l.Tasks[i].ID == id {
+		return i
+	}
+	for i, task := range l.Tasks {
+		if task.ID == id {
+			l.reindex()
+			return i
+		}
+	}
+	return -1
+}
+
+// reindex rebuilds the index of task positions by ID. Where IDs repeat,
+// the first task wins, as in a scan.
+func (l *TaskList) reindex() {
+	l.index = make(map[int]int, len(l.Tasks))
+	for i := len(l.Tasks) - 1; i >= 0; i-- {
+		l.index[l.Tasks[i].ID] = i
+	}
+}
+
+// LiveIndex returns the index of the task with the given ID, or -1 if
+// there is none or it is in the trash.
+func (l *TaskList) LiveIndex(id int) int {
+	i := l.Index(id)
+	if i >= 0 && l.Tasks[i].Trashed() {
+		return -1
+	}
+	return i
+}
+
+// Live returns the tasks that are not in the trash.
+func (l *TaskList) Live() []Task {
+	live := make([]Task, 0, len(l.Tasks))
+	for _, task := range l.Tasks {
+		if !task.Trashed() {
+			live = append(live, task)
+		}
+	}
+	return live
+}
+
+// Trash returns the tasks that are in the trash.
+func (l *TaskList) Trash() []Task {
+	var trash []Task
+	for _, task := range l.Tasks {
+		if task.Trashed() {
+			trash = append(trash, task)
+		}
+	}
+	return trash
+}
+
+// Clone returns a copy of the list that shares no tasks with l.
+func (l *TaskList) Clone() *TaskList {
+	c := *l
+	c.index = nil
+	c.Tasks = append([]Task{}, l.Tasks...)
+	c.Tombstones = append([]Tombstone(nil), l.Tombstones...)
+	return &c
+}
+
+// fixNextID makes sure NextID is above every ID in use, e.g. for files
+// written before the counter existed.
+func (l *TaskList) fixNextID() {
+	if l.NextID < 1 {
+		l.NextID = 1
+	}
+	for _, task := range l.Tasks {
+		if task.ID >= l.NextID {
+			l.NextID = task.ID + 1
+		}
+	}
+}
+
+// hasDuplicateIDs reports whether two tasks share an ID.
+func (l *TaskList) hasDuplicateIDs() bool {
+	seen := make(map[int]bool, len(l.Tasks))
+	for _, task := range l.Tasks {
+		if seen[task.ID] {
+			return true
+		}
+		seen[task.ID] = true
+	}
+	return false
+}
+
+// repairDuplicateIDs gives every task that repeats an earlier task's ID a
+// new ID and returns the changes made. The first task with an ID keeps it.
+func (l *TaskList) repairDuplicateIDs() []IDChange {
+	l.fixNextID()
+	var changes []IDChange
+	seen := make(map[int]bool, len(l.Tasks))
+	for i, task := range l.Tasks {
+		if seen[task.ID] {
+			l.Tasks[i].ID = l.NextID
+			l.NextID++
+			changes = append(changes, IDChange{OldID: task.ID, NewID: l.Tasks[i].ID, Description: task.Description})
+			continue
+		}
+		seen[task.ID] = true
+	}
+	return changes
+}
+
+// Put replaces the task with the same ID, or inserts task in ID order if
+// there is none. A tombstone for the task is dropped.
+func (l *TaskList) Put(task Task) {
+	l.dropTombstone(task.uid())
+	if i := l.Index(task.ID); i >= 0 {
+		l.Tasks[i] = task
+		return
+	}
+	i := len(l.Tasks)
+	for j, t := range l.Tasks {
+		if t.ID > task.ID {
+			i = j
+			break
+		}
+	}
+	l.Tasks = append(l.Tasks[:i], append([]Task{task}, l.Tasks[i:]...)...)
+	l.fixNextID()
+}
+
+// Remove deletes the task with the given ID for good, leaving a tombstone,
+// and reports whether it was there.
+func (l *TaskList) Remove(id int) bool {
+	return l.removeAt(id, time.Now())
+}
+
+// removeAt is Remove with the time for the tombstone.
+func (l *TaskList) removeAt(id int, at time.Time) bool {
+	i := l.Index(id)
+	if i < 0 {
+		return false
+	}
+	l.bury(l.Tasks[i], at)
+	l.Tasks = append(l.Tasks[:i], l.Tasks[i+1:]...)
+	return true
+}
+
+// bury records a tombstone for task, which is being removed at the given
+// time.
+func (l *TaskList) bury(task Task, at time.Time) {
+	uid := task.uid()
+	l.dropTombstone(uid)
+	l.Tombstones = append(l.Tombstones, Tombstone{UID: uid, At: at})
+}
+
+// dropTombstone removes the tombstone for uid, if any.
+func (l *TaskList) dropTombstone(uid string) {
+	for i, t := range l.Tombstones {
+		if t.UID == uid {
+			l.Tombstones = append(l.Tombstones[:i], l.Tombstones[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package tracker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStableTaskIDs tests that task IDs are never reused.
+//
+// The test includes the following cases:
+//
+//  1. Delete then add: Add three tasks, delete task 2 and add another. The test
+//     checks that the new task gets ID 4.
+//
+//  2. Delete highest ID: Delete task 4 and add another. The test checks that the
+//     new task gets ID 5, i.e. the counter is persisted in the file.
+func TestStableTaskIDs(t *testing.T) {
+	tr := New(NewJSONFileStore(filepath.Join(t.TempDir(), "tasks.json")))
+
+	// Case 1: Hapus task 2 lalu tambah task baru
+	for _, d := range []string{"One", "Two", "Three"} {
+		if _, err := tr.Add(d); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if _, err := tr.Delete(2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tr.Add("Four")
+	if task, err := tr.Store.Get(4); err != nil || task.Description != "Four" {
+		t.Fatalf("Expected task 4 'Four', got %+v (err %v)", task, err)
+	}
+
+	// Case 2: Hapus task dengan ID tertinggi lalu tambah task baru
+	tr.Delete(4)
+	tr.Add("Five")
+	if task, err := tr.Store.Get(5); err != nil || task.Description != "Five" {
+		t.Fatalf("Expected task 5 'Five', got %+v (err %v)", task, err)
+	}
+}
+
+// TestRepairDuplicateIDs tests the one-time repair of duplicate IDs on load.
+//
+// The test includes the following cases:
+//
+//  1. Legacy file with duplicates: Load a bare task array in which ID 3 appears
+//     twice. The test checks that the second task is renumbered to 4, that the
+//     repaired list is saved and that the next ID is 5.
+func TestRepairDuplicateIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(path, []byte(`[
+  {"id": 1, "description": "One", "status": "todo"},
+  {"id": 3, "description": "Three", "status": "todo"},
+  {"id": 3, "description": "Also three", "status": "done"}
+]`), 0644)
+	s := NewJSONFileStore(path)
+
+	// Case 1: File lama dengan ID duplikat
+	list, err := s.Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if list.Tasks[2].ID != 4 || list.Tasks[2].Description != "Also three" {
+		t.Errorf("Expected 'Also three' renumbered to 4, got %+v", list.Tasks[2])
+	}
+	if list.NextID != 5 {
+		t.Errorf("Expected next ID 5, got %d", list.NextID)
+	}
+	saved, err := readTaskListFile(nil, path)
+	if err != nil || saved.hasDuplicateIDs() {
+		t.Errorf("Expected repaired file to be saved, got %+v (err %v)", saved, err)
+	}
+}
@@ -0,0 +1,382 @@
+// Package tracker keeps task lists: it stores them in task files (see
+// TaskStore) and changes them through a Tracker, whose methods return the
+// tasks they affected and never print. The task-cli command is built on
+// top of it.
+//
+//	tr := tracker.New(tracker.NewJSONFileStore("tasks.json"))
+//	task, err := tr.Add("Write the report")
+//	if errors.Is(err, tracker.ErrEmptyDescription) {
+//		...
+//	}
+package tracker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Task is a task in a task list.
+type Task struct {
+	ID          int       `json:"id"`
+	Description string    `json:"description"`
+	Status      Status    `json:"status"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	// Priority is left out of the file when it is PriorityNone
+	Priority Priority `json:"priority,omitempty"`
+	// DueAt is the day the task is due, as midnight; see ParseDue
+	DueAt *time.Time `json:"dueAt,omitempty"`
+	// Tags are sorted and in lower case; see ParseTag
+	Tags []string `json:"tags,omitempty"`
+	// ParentID is the ID of the task this one is a subtask of, 0 if none
+	ParentID int `json:"parentId,omitempty"`
+	// DeletedAt is set while the task is in the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// UID identifies the task across task files, which may give it
+	// different IDs; see Sync. Tasks from older files have none until
+	// they are synced; see uid.
+	UID string `json:"uid,omitempty"`
+}
+
+// Trashed reports whether the task has been deleted to the trash.
+func (t Task) Trashed() bool {
+	return t.DeletedAt != nil
+}
+
+// uid returns the task's UID. Tasks written before UIDs existed get one
+// derived from their ID and creation time, so copies of the same task file
+// agree on it.
+func (t Task) uid() string {
+	if t.UID != "" {
+		return t.UID
+	}
+	sum := sha256.Sum256([]byte(strconv.Itoa(t.ID) + "|" + t.CreatedAt.UTC().Format(time.RFC3339Nano)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// lastChange returns when the task was last changed, counting moving it to
+// the trash.
+func (t Task) lastChange() time.Time {
+	if t.DeletedAt != nil && t.DeletedAt.After(t.UpdatedAt) {
+		return *t.DeletedAt
+	}
+	return t.UpdatedAt
+}
+
+// newUID returns a random UID for a new task.
+func newUID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Errors returned by the Tracker methods, for use with errors.Is.
+var (
+	// ErrInvalidID is returned for task IDs that are not positive numbers.
+	ErrInvalidID = errors.New("invalid task ID")
+	// ErrTaskNotFound is returned when no task outside the trash has the
+	// requested ID.
+	ErrTaskNotFound = errors.New("task ID not found")
+	// ErrNotInTrash is returned when no task in the trash has the requested
+	// ID.
+	ErrNotInTrash = errors.New("task ID not found in trash")
+	// ErrEmptyDescription is returned for task descriptions that are empty
+	// or just spaces.
+	ErrEmptyDescription = errors.New("task description cannot be empty or just spaces")
+)
+
+// Tracker runs the task commands against a store. Its methods print
+// nothing; they return the tasks they affected.
+type Tracker struct {
+	Store TaskStore
+	// Workflow gives the statuses tasks can have; DefaultWorkflow if nil.
+	Workflow Workflow
+	// Clock returns the current time that due dates are judged against;
+	// time.Now if nil.
+	Clock func() time.Time
+	// Subtasks says what happens to the subtasks of deleted and closed
+	// tasks; ChildrenBlock if empty.
+	Subtasks ChildPolicy
+}
+
+// New returns a Tracker for the tasks in store.
+func New(store TaskStore) *Tracker {
+	return &Tracker{Store: store}
+}
+
+// workflow returns the tracker's workflow.
+func (t *Tracker) workflow() Workflow {
+	if len(t.Workflow) == 0 {
+		return DefaultWorkflow
+	}
+	return t.Workflow
+}
+
+// Now returns the current time of the tracker's clock.
+func (t *Tracker) Now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
+	}
+	return t.Clock()
+}
+
+// ParseID parses a task ID given as text, such as a command line argument.
+func ParseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidID
+	}
+	return id, nil
+}
+
+// Add adds a new task with the given description and returns it.
+func (t *Tracker) Add(description string) (Task, error) {
+	return t.AddTask(Task{Description: description})
+}
+
+// AddTask adds a new task with the description, priority, due date, tags
+// and parent of draft and returns it. The ID, status and timestamps are set
+// by the tracker.
+func (t *Tracker) AddTask(draft Task) (Task, error) {
+	// Validate task description
+	if err := ValidateDescription(draft.Description); err != nil {
+		return Task{}, err
+	}
+	if !draft.Priority.valid() {
+		return Task{}, fmt.Errorf("%w %d", ErrUnknownPriority, int(draft.Priority))
+	}
+	tags, err := parseTags(draft.Tags)
+	if err != nil {
+		return Task{}, err
+	}
+
+	var newTask Task
+	err = t.Store.Modify(func(list *TaskList) error {
+		if err := checkParent(list, 0, draft.ParentID); err != nil {
+			return err
+		}
+		// Create new task; the list assigns the next unused ID
+		task := Task{
+			Description: draft.Description,
+			Status:      t.workflow().Initial(), // Status awal dari workflow
+			Priority:    draft.Priority,
+			DueAt:       draft.DueAt,
+			ParentID:    draft.ParentID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		retag(&task, tags, nil)
+		newTask = list.Add(task)
+		return nil
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return newTask, nil
+}
+
+// Update gives the task with the given ID a new description and returns
+// the updated task.
+func (t *Tracker) Update(id int, description string) (Task, error) {
+	return t.UpdateTask(id, TaskUpdate{Description: &description})
+}
+
+// TaskUpdate holds the fields UpdateTask changes; nil fields are kept.
+type TaskUpdate struct {
+	Description *string
+	Priority    *Priority
+	// DueAt sets the due date; the zero time removes it.
+	DueAt *time.Time
+	// ParentID makes the task a subtask of another; 0 makes it a
+	// top-level task.
+	ParentID *int
+}
+
+// UpdateTask changes the fields of the task with the given ID that update
+// sets and returns the updated task.
+func (t *Tracker) UpdateTask(id int, update TaskUpdate) (Task, error) {
+	// Validasi deskripsi dan prioritas task
+	if update.Description != nil {
+		if err := ValidateDescription(*update.Description); err != nil {
+			return Task{}, err
+		}
+	}
+	if update.Priority != nil && !update.Priority.valid() {
+		return Task{}, fmt.Errorf("%w %d", ErrUnknownPriority, int(*update.Priority))
+	}
+	return t.modifyListTask(id, func(list *TaskList, i int) error {
+		task := &list.Tasks[i]
+		if update.ParentID != nil {
+			if err := checkParent(list, id, *update.ParentID); err != nil {
+				return err
+			}
+			task.ParentID = *update.ParentID
+		}
+		// Task ditemukan, lakukan update field dan updatedAt
+		if update.Description != nil {
+			task.Description = *update.Description
+		}
+		if update.Priority != nil {
+			task.Priority = *update.Priority
+		}
+		if update.DueAt != nil {
+			task.DueAt = update.DueAt
+			if update.DueAt.IsZero() {
+				task.DueAt = nil
+			}
+		}
+		task.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// Delete moves the task with the given ID to the trash, from which Restore
+// brings it back, and returns it. Its subtasks are dealt with as the
+// tracker's child policy says; see ChildPolicy.
+func (t *Tracker) Delete(id int) (Task, error) {
+	return t.modifyListTask(id, func(list *TaskList, i int) error {
+		return t.deleteTask(list, i, time.Now())
+	})
+}
+
+// Mark sets the status of the task with the given ID and returns the
+// updated task. The workflow must declare status and let the task's current
+// status lead to it (see Workflow.CanTransition), and moving back to the
+// initial status takes Reopen; otherwise a *TransitionError is returned.
+// Closing a task deals with its open subtasks as the tracker's child policy
+// says; see ChildPolicy.
+func (t *Tracker) Mark(id int, status Status) (Task, error) {
+	workflow := t.workflow()
+	if _, err := workflow.Parse(string(status)); err != nil {
+		return Task{}, err
+	}
+	return t.modifyListTask(id, func(list *TaskList, i int) error {
+		task := &list.Tasks[i]
+		if task.Status != status && (status == workflow.Initial() || !workflow.CanTransition(task.Status, status)) {
+			return workflow.transitionError(*task, status)
+		}
+		now := time.Now()
+		if workflow.Closed(status) && !workflow.Closed(task.Status) {
+			if err := t.closeSubtasks(list, i, status, now); err != nil {
+				return err
+			}
+		}
+		task.Status = status // Update status
+		task.UpdatedAt = now // Update waktu
+		return nil
+	})
+}
+
+// Reopen moves the task with the given ID back to the initial status of
+// the workflow, todo by default, and returns the updated task. The task's
+// status must lead back to it.
+func (t *Tracker) Reopen(id int) (Task, error) {
+	workflow := t.workflow()
+	initial := workflow.Initial()
+	return t.modifyTask(id, func(task *Task) error {
+		if task.Status == initial || !workflow.CanTransition(task.Status, initial) {
+			return workflow.transitionError(*task, initial)
+		}
+		task.Status = initial
+		task.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// modifyTask applies change to the task with the given ID that is not in
+// the trash, saves it and returns the changed task. Nothing is saved if
+// change returns an error.
+func (t *Tracker) modifyTask(id int, change func(task *Task) error) (Task, error) {
+	return t.modifyListTask(id, func(list *TaskList, i int) error {
+		return change(&list.Tasks[i])
+	})
+}
+
+// modifyListTask is modifyTask for changes that reach other tasks of the
+// list; change gets the index of the task with the given ID.
+func (t *Tracker) modifyListTask(id int, change func(list *TaskList, i int) error) (Task, error) {
+	if id <= 0 {
+		return Task{}, ErrInvalidID
+	}
+
+	// Cari task dengan ID yang cocok lalu simpan hasilnya
+	var changed Task
+	err := t.Store.Modify(func(list *TaskList) error {
+		i := list.LiveIndex(id)
+		if i < 0 {
+			return ErrTaskNotFound
+		}
+		if err := change(list, i); err != nil {
+			return err
+		}
+		changed = list.Tasks[i]
+		return nil
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return changed, nil
+}
+
+// Get returns the task with the given ID, which may be in the trash.
+func (t *Tracker) Get(id int) (Task, error) {
+	if id <= 0 {
+		return Task{}, ErrInvalidID
+	}
+	return t.Store.Get(id)
+}
+
+// Tasks returns the tasks that are not in the trash.
+func (t *Tracker) Tasks() ([]Task, error) {
+	list, err := t.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return list.Live(), nil
+}
+
+// List returns the tasks that are not in the trash and have one of the
+// given statuses, or all of them if no status is given, in file order. See
+// Query for other filters and orders.
+func (t *Tracker) List(statuses ...Status) ([]Task, error) {
+	return t.Query(Query{Statuses: statuses, Sort: SortID})
+}
+
+// hasStatus reports whether task has one of statuses, or if statuses is
+// empty.
+func hasStatus(task Task, statuses []Status) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, status := range statuses {
+		if task.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplaceTasks replaces the tasks that are not in the trash, keeping the
+// ID counter and the trash.
+func (t *Tracker) ReplaceTasks(tasks []Task) error {
+	return t.Store.Modify(func(list *TaskList) error {
+		saved := make(map[int]bool, len(tasks))
+		for _, task := range tasks {
+			saved[task.ID] = true
+		}
+		for _, task := range list.Trash() {
+			if !saved[task.ID] {
+				tasks = append(tasks, task)
+			}
+		}
+		list.Tasks = tasks
+		return nil
+	})
+}
@@ -0,0 +1,103 @@
+package tracker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trash returns the tasks in the trash.
+func (t *Tracker) Trash() ([]Task, error) {
+	list, err := t.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return list.Trash(), nil
+}
+
+// Restore brings the task with the given ID back from the trash and
+// returns it. It keeps its original ID. Subtasks deleted along with it
+// under ChildrenCascade come back too.
+func (t *Tracker) Restore(id int) (Task, error) {
+	if id <= 0 {
+		return Task{}, ErrInvalidID
+	}
+
+	var restored Task
+	err := t.Store.Modify(func(list *TaskList) error {
+		i := list.Index(id)
+		if i < 0 || !list.Tasks[i].Trashed() {
+			return ErrNotInTrash
+		}
+		deletedAt := *list.Tasks[i].DeletedAt
+		now := time.Now()
+		list.Tasks[i].DeletedAt = nil
+		list.Tasks[i].UpdatedAt = now
+		restored = list.Tasks[i]
+
+		parents := map[int]bool{id: true}
+		for again := true; again; {
+			again = false
+			for j := range list.Tasks {
+				task := &list.Tasks[j]
+				if task.Trashed() && parents[task.ParentID] && task.DeletedAt.Equal(deletedAt) {
+					task.DeletedAt = nil
+					task.UpdatedAt = now
+					parents[task.ID] = true
+					again = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return Task{}, err
+	}
+	return restored, nil
+}
+
+// PurgeTrash removes tasks from the trash for good and returns how many it
+// removed. With olderThan above zero only tasks deleted at least that long
+// ago are removed.
+func (t *Tracker) PurgeTrash(olderThan time.Duration) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-olderThan)
+	purged := 0
+	err := t.Store.Modify(func(list *TaskList) error {
+		kept := list.Tasks[:0]
+		for _, task := range list.Tasks {
+			if task.Trashed() && !task.DeletedAt.After(cutoff) {
+				list.bury(task, now)
+				purged++
+				continue
+			}
+			kept = append(kept, task)
+		}
+		list.Tasks = kept
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// ParseAge parses a duration such as "30d", "12h" or "1h30m". Besides the
+// units of time.ParseDuration it accepts "d" for days and "w" for weeks.
+func ParseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,92 @@
+package tracker
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestTrash tests deleting to the trash, restoring and purging.
+//
+// The test includes the following cases:
+//
+//  1. Delete: Delete a task. The test checks that it is hidden from LoadTasks
+//     but kept in the trash, and that it can no longer be updated.
+//
+//  2. Restore: Restore the task. The test checks that it is back with its
+//     original ID.
+//
+//  3. Purge with age: Delete the task again and purge tasks older than a day.
+//     The test checks that the recently deleted task is kept.
+//
+//  4. Purge: Purge the whole trash. The test checks that the task is gone and
+//     that its ID is not reused.
+func TestTrash(t *testing.T) {
+	tr := New(NewJSONFileStore(filepath.Join(t.TempDir(), "tasks.json")))
+	tr.Add("Keep me")
+	tr.Add("Trash me")
+
+	// Case 1: Hapus task ke trash
+	if _, err := tr.Delete(2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tasks, _ := tr.Tasks()
+	if len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Errorf("Expected only task 1 to be listed, got %+v", tasks)
+	}
+	list, _ := tr.Store.Load()
+	if trash := list.Trash(); len(trash) != 1 || trash[0].ID != 2 {
+		t.Errorf("Expected task 2 in the trash, got %+v", trash)
+	}
+	if _, err := tr.Update(2, "Changed"); err == nil || err.Error() != "task ID not found" {
+		t.Errorf("Expected 'task ID not found' error, got %v", err)
+	}
+
+	// Case 2: Kembalikan task dari trash
+	if _, err := tr.Restore(2); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tasks, _ = tr.Tasks()
+	if len(tasks) != 2 || tasks[1].ID != 2 || tasks[1].Description != "Trash me" {
+		t.Errorf("Expected task 2 restored, got %+v", tasks)
+	}
+
+	// Case 3: Purge hanya task yang lebih lama dari satu hari
+	tr.Delete(2)
+	if _, err := tr.PurgeTrash(24 * time.Hour); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	list, _ = tr.Store.Load()
+	if len(list.Trash()) != 1 {
+		t.Errorf("Expected recently deleted task to be kept, got %+v", list.Trash())
+	}
+
+	// Case 4: Purge seluruh trash
+	tr.PurgeTrash(0)
+	list, _ = tr.Store.Load()
+	if len(list.Tasks) != 1 || list.NextID != 3 {
+		t.Errorf("Expected 1 task and next ID 3, got %+v", list)
+	}
+}
+
+// TestParseAge tests parsing of trash purge ages.
+//
+// The test includes the following cases:
+//
+//  1. Days, weeks and Go durations: The test checks the parsed values.
+//
+//  2. Invalid age: The test checks that an error is returned.
+func TestParseAge(t *testing.T) {
+	// Case 1: Hari, minggu dan durasi Go
+	for in, want := range map[string]time.Duration{"30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "1h30m": 90 * time.Minute} {
+		got, err := ParseAge(in)
+		if err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	// Case 2: Umur tidak valid
+	if _, err := ParseAge("soon"); err == nil {
+		t.Fatal("Expected error for invalid age, got none")
+	}
+}
@@ -0,0 +1,105 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ValidateDescription validates the task description
+func ValidateDescription(description string) error {
+	trimmed := strings.TrimSpace(description)
+	if len(trimmed) == 0 {
+		return ErrEmptyDescription
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it to
+// disk and renames it over path, so readers see either the old or the new
+// contents but never a partial write.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, tempPrefix(path)+"*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op once the rename succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash. Not every
+	// platform can open a directory for syncing, so this is best effort.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// tempPrefix is the name prefix of the temporary files writeFileAtomic
+// creates for path.
+func tempPrefix(path string) string {
+	return "." + filepath.Base(path) + ".tmp-"
+}
+
+// errLockTimeout is returned when the task file lock cannot be acquired in
+// time.
+var errLockTimeout = errors.New("timed out waiting for the task file lock")
+
+// lockFile waits up to timeout for an exclusive lock on the file at path and
+// returns the function that releases it.
+func lockFile(path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock, ok, err := tryLockFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return unlock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w %s after %s; is another task-cli still running?", errLockTimeout, path, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// appendFile appends data to the file at path with a single write and
+// syncs it to disk.
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,206 @@
+package tracker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StatusDef declares a status of a Workflow.
+type StatusDef struct {
+	Name Status `json:"name"`
+	// Label is how the status is shown in lists; it defaults to the name.
+	Label string `json:"label,omitempty"`
+	// Closed marks statuses that finish a task, such as done. Tasks with
+	// any other status are open.
+	Closed bool `json:"closed,omitempty"`
+	// Next lists the statuses a task with this status may be marked as.
+	Next []Status `json:"next,omitempty"`
+}
+
+// Workflow is the statuses tasks can have and the changes allowed between
+// them, as set by the statuses of the config file. The first status is the
+// one new tasks get. Tasks move back to it only through Reopen, and only
+// from statuses that list it in Next.
+type Workflow []StatusDef
+
+// DefaultWorkflow is the workflow used when the config declares none:
+// todo, in-progress and done, where done is closed and every status but
+// todo can be reopened.
+var DefaultWorkflow = Workflow{
+	{Name: StatusTodo, Next: []Status{StatusInProgress, StatusDone}},
+	{Name: StatusInProgress, Next: []Status{StatusTodo, StatusDone}},
+	{Name: StatusDone, Closed: true, Next: []Status{StatusTodo}},
+}
+
+// reservedStatuses are words the list command uses besides the status
+// names, so no status may have them as its name.
+var reservedStatuses = []Status{"all", "open", "closed"}
+
+// Validate checks that the names of the statuses are well-formed, unique
+// and not reserved, that every next status is declared, that the first
+// status is open and that at least one status is closed.
+func (w Workflow) Validate() error {
+	if len(w) == 0 {
+		return errors.New("the workflow has no statuses")
+	}
+	if w[0].Closed {
+		return fmt.Errorf("status %q: the first status cannot be closed", w[0].Name)
+	}
+	seen := make(map[Status]bool)
+	for _, def := range w {
+		if !validStatusName(string(def.Name)) {
+			return fmt.Errorf("status %q: the name must be lower case letters, digits and dashes", def.Name)
+		}
+		for _, reserved := range reservedStatuses {
+			if def.Name == reserved {
+				return fmt.Errorf("status %q: the name is reserved", def.Name)
+			}
+		}
+		if seen[def.Name] {
+			return fmt.Errorf("status %q is declared twice", def.Name)
+		}
+		seen[def.Name] = true
+	}
+	closed := false
+	for _, def := range w {
+		closed = closed || def.Closed
+		for _, next := range def.Next {
+			if !w.Has(next) {
+				return fmt.Errorf("status %q: next status %q is not declared", def.Name, next)
+			}
+		}
+	}
+	if !closed {
+		return errors.New("the workflow has no closed status")
+	}
+	return nil
+}
+
+// Initial returns the status new tasks get.
+func (w Workflow) Initial() Status {
+	if len(w) == 0 {
+		return StatusTodo
+	}
+	return w[0].Name
+}
+
+// Statuses returns the names of the statuses, in workflow order.
+func (w Workflow) Statuses() []Status {
+	statuses := make([]Status, len(w))
+	for i, def := range w {
+		statuses[i] = def.Name
+	}
+	return statuses
+}
+
+// Lookup returns the declaration of status s.
+func (w Workflow) Lookup(s Status) (StatusDef, bool) {
+	for _, def := range w {
+		if def.Name == s {
+			return def, true
+		}
+	}
+	return StatusDef{}, false
+}
+
+// Has reports whether the workflow declares status s.
+func (w Workflow) Has(s Status) bool {
+	_, ok := w.Lookup(s)
+	return ok
+}
+
+// Parse returns the status named s if the workflow declares it.
+func (w Workflow) Parse(s string) (Status, error) {
+	status, err := ParseStatus(s)
+	if err != nil {
+		return "", err
+	}
+	if !w.Has(status) {
+		return "", fmt.Errorf("%w %q (want %s)", ErrUnknownStatus, s, w.describe())
+	}
+	return status, nil
+}
+
+// Filter returns the statuses the list command shows for name: none,
+// meaning every status, for "all", the open or closed statuses for "open"
+// and "closed", and else the status named name.
+func (w Workflow) Filter(name string) ([]Status, error) {
+	switch name {
+	case "all":
+		return nil, nil
+	case "open", "closed":
+		var statuses []Status
+		for _, def := range w {
+			if def.Closed == (name == "closed") {
+				statuses = append(statuses, def.Name)
+			}
+		}
+		return statuses, nil
+	}
+	status, err := w.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	return []Status{status}, nil
+}
+
+// describe lists the status names for error messages, e.g. "todo,
+// in-progress or done".
+func (w Workflow) describe() string {
+	names := make([]string, len(w))
+	for i, def := range w {
+		names[i] = string(def.Name)
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// Label returns how status s is shown: its label, or else its name.
+func (w Workflow) Label(s Status) string {
+	if def, ok := w.Lookup(s); ok && def.Label != "" {
+		return def.Label
+	}
+	return string(s)
+}
+
+// Closed reports whether status s finishes a task. Statuses the workflow
+// does not declare count as open.
+func (w Workflow) Closed(s Status) bool {
+	def, ok := w.Lookup(s)
+	return ok && def.Closed
+}
+
+// CanTransition reports whether a task with status from may move to status
+// to. Staying at a declared status is always allowed. A task whose status
+// the workflow no longer declares, for example after it was removed from
+// the config, may move to any declared status, so it is never stuck.
+func (w Workflow) CanTransition(from, to Status) bool {
+	def, ok := w.Lookup(from)
+	if !ok {
+		return w.Has(to)
+	}
+	if from == to {
+		return true
+	}
+	for _, next := range def.Next {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError returns the error for moving the task to status to.
+func (w Workflow) transitionError(task Task, to Status) *TransitionError {
+	initial := w.Initial()
+	return &TransitionError{
+		ID:         task.ID,
+		From:       task.Status,
+		To:         to,
+		Initial:    initial,
+		Reopenable: task.Status != initial && w.CanTransition(task.Status, initial),
+	}
+}
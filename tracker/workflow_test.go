@@ -0,0 +1,119 @@
+package tracker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWorkflow tests a workflow declared in the config file.
+//
+// The test includes the following cases:
+//
+//  1. Config: Load a config declaring todo, in-progress, review, blocked and
+//     done. The test checks that the statuses and labels are read.
+//
+//  2. Transitions: Move a task through in-progress, review and done, and
+//     block another one. The test checks that the declared changes are made
+//     and the others refused.
+//
+//  3. Reopen: Reopen the done task and the blocked task. The test checks
+//     that both are todo again.
+//
+//  4. Filter: The test checks that "open" and "closed" give the statuses
+//     with the matching Closed setting and that unknown names are refused.
+//
+//  5. Invalid config: The test checks that a workflow with an undeclared
+//     next status, a reserved name or no closed status is refused.
+//
+//  6. Removed status: Remove blocked and review from the config while tasks
+//     are in them. The test checks that one can be reopened and the other
+//     marked with a declared status.
+func TestWorkflow(t *testing.T) {
+	// Case 1: Config dengan status sendiri
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("TASK_CLI_CONFIG", path)
+	os.WriteFile(path, []byte(`{"statuses": [
+		{"name": "todo", "label": "To do", "next": ["in-progress", "blocked"]},
+		{"name": "in-progress", "label": "In progress", "next": ["review", "blocked", "todo"]},
+		{"name": "review", "label": "In review", "next": ["in-progress", "done"]},
+		{"name": "blocked", "label": "Blocked", "next": ["todo", "in-progress"]},
+		{"name": "done", "label": "Done", "closed": true, "next": ["todo"]}
+	]}`), 0644)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	workflow := cfg.Statuses
+	if len(workflow) != 5 || workflow.Label("review") != "In review" || !workflow.Closed("done") {
+		t.Fatalf("Unexpected workflow %+v", workflow)
+	}
+
+	// Case 2: Perubahan status
+	tr := &Tracker{Store: NewMemoryStore(), Workflow: workflow}
+	tr.Add("Ship it")
+	tr.Add("Wait for it")
+	for _, status := range []Status{"in-progress", "review", "done"} {
+		if _, err := tr.Mark(1, status); err != nil {
+			t.Fatalf("Expected task 1 %s, got %v", status, err)
+		}
+	}
+	if _, err := tr.Mark(2, "blocked"); err != nil {
+		t.Fatalf("Expected task 2 blocked, got %v", err)
+	}
+	if _, err := tr.Mark(2, "done"); !errors.Is(err, ErrIllegalTransition) || !strings.Contains(err.Error(), "reopen it first") {
+		t.Errorf("Expected blocked -> done to be refused, got %v", err)
+	}
+
+	// Case 3: Reopen
+	for _, id := range []int{1, 2} {
+		if task, err := tr.Reopen(id); err != nil || task.Status != StatusTodo {
+			t.Errorf("Expected task %d todo, got %+v (err %v)", id, task, err)
+		}
+	}
+
+	// Case 4: Filter open dan closed
+	open, _ := workflow.Filter("open")
+	closed, _ := workflow.Filter("closed")
+	if len(open) != 4 || len(closed) != 1 || closed[0] != "done" {
+		t.Errorf("Expected 4 open and 1 closed status, got %v and %v", open, closed)
+	}
+	if _, err := workflow.Filter("wip"); !errors.Is(err, ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus, got %v", err)
+	}
+
+	// Case 5: Config yang tidak valid
+	for _, statuses := range []string{
+		`[{"name": "todo", "next": ["doing"]}, {"name": "done", "closed": true}]`,
+		`[{"name": "todo"}, {"name": "open"}, {"name": "done", "closed": true}]`,
+		`[{"name": "todo", "next": ["doing"]}, {"name": "doing"}]`,
+	} {
+		os.WriteFile(path, []byte(`{"statuses": `+statuses+`}`), 0644)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("Expected an error for %s", statuses)
+		}
+	}
+
+	// Case 6: Status dihapus dari config
+	tr.Mark(1, "in-progress")
+	tr.Mark(1, "review")
+	tr.Mark(2, "blocked")
+	os.WriteFile(path, []byte(`{"statuses": [
+		{"name": "todo", "next": ["in-progress"]},
+		{"name": "in-progress", "next": ["done"]},
+		{"name": "done", "closed": true, "next": ["todo"]}
+	]}`), 0644)
+	cfg, err = LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tr.Workflow = cfg.Statuses
+	if task, err := tr.Reopen(2); err != nil || task.Status != StatusTodo {
+		t.Errorf("Expected the blocked task to be reopened, got %+v (err %v)", task, err)
+	}
+	if task, err := tr.Mark(1, StatusDone); err != nil || task.Status != StatusDone {
+		t.Errorf("Expected the task in review to be marked done, got %+v (err %v)", task, err)
+	}
+}